@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/MateoCaicedoW/gomp3/internal/system/services/mp3"
+)
+
+// printDetails prints the metadata shown by -i after the title, author
+// and duration.
+func printDetails(info *mp3.VideoInfo) {
+	fmt.Printf("Video ID: %s\n", info.VideoID)
+	if info.ChannelID != "" {
+		fmt.Printf("Channel:  %s\n", info.ChannelID)
+	}
+	if !info.PublishDate.IsZero() {
+		fmt.Printf("Uploaded: %s\n", info.PublishDate.Format("2006-01-02"))
+	}
+	fmt.Printf("Views:    %d\n", info.Views)
+	if thumb, ok := info.BestThumbnail(); ok {
+		fmt.Printf("Thumbnail: %s (%dx%d)\n", thumb.URL, thumb.Width, thumb.Height)
+	}
+
+	if info.Description != "" {
+		fmt.Println("\nDescription:")
+		for _, line := range strings.Split(strings.TrimSpace(info.Description), "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	if len(info.Chapters) > 0 {
+		fmt.Println("\nChapters:")
+		printChapters(info.Chapters)
+	}
+	if tracklist := info.ChaptersFrom(mp3.ChaptersDescription); len(tracklist) > 0 {
+		fmt.Println("\nTracklist in the description (-chapters description):")
+		printChapters(tracklist)
+	}
+
+	if len(info.AudioFormats) == 0 {
+		return
+	}
+
+	fmt.Println("\nAudio formats:")
+	printFormats(info.AudioFormats, false)
+}
+
+// printFormats prints a table of audio formats. When chosen is set the
+// first format is marked as the one that will be downloaded.
+func printFormats(formats []mp3.AudioFormat, chosen bool) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  ID\tCODEC\tCONTAINER\tBITRATE\tSAMPLE RATE\tCHANNELS\tSIZE\tLANGUAGE")
+	for i, f := range formats {
+		marker := " "
+		if chosen && i == 0 {
+			marker = "*"
+		}
+
+		fmt.Fprintf(tw, "%s %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			marker,
+			f.ID,
+			f.Codec,
+			f.Container,
+			orUnknown(f.Bitrate > 0, fmt.Sprintf("%dk", f.Bitrate/1000)),
+			orUnknown(f.SampleRate > 0, fmt.Sprintf("%d Hz", f.SampleRate)),
+			orUnknown(f.Channels > 0, fmt.Sprintf("%d", f.Channels)),
+			orUnknown(f.Size > 0, formatBytes(f.Size)),
+			orUnknown(f.Language != "", f.Language),
+		)
+	}
+	tw.Flush()
+}
+
+// sourceLabel describes the downloaded stream, such as
+// "251 (opus, 160k, 48000 Hz, 2 channels)".
+func sourceLabel(f mp3.AudioFormat) string {
+	details := []string{f.Codec}
+	if f.Bitrate > 0 {
+		details = append(details, fmt.Sprintf("%dk", f.Bitrate/1000))
+	}
+	if f.SampleRate > 0 {
+		details = append(details, fmt.Sprintf("%d Hz", f.SampleRate))
+	}
+	if f.Channels > 0 {
+		details = append(details, fmt.Sprintf("%d channels", f.Channels))
+	}
+	return fmt.Sprintf("%s (%s)", f.ID, strings.Join(details, ", "))
+}
+
+func orUnknown(known bool, value string) string {
+	if !known {
+		return "-"
+	}
+	return value
+}
+
+// printChapters prints the start and title of every chapter.
+func printChapters(chapters []mp3.Chapter) {
+	for _, c := range chapters {
+		fmt.Printf("  %8s  %s\n", formatClock(c.Start), c.Title)
+	}
+}
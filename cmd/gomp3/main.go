@@ -1,12 +1,19 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/MateoCaicedoW/gomp3/internal/system/services/mp3"
 )
@@ -14,20 +21,67 @@ import (
 func main() {
 	var (
 		output     = flag.String("o", "", "Output filename (default: video title)")
-		bitrate    = flag.String("b", "64k", "Audio bitrate (e.g., 64k, 128k, 192k)")
-		sampleRate = flag.Int("r", 22050, "Sample rate in Hz (e.g., 22050, 44100)")
-		channels   = flag.Int("c", 1, "Audio channels: 1 for mono, 2 for stereo")
+		bitrate    = flag.String("b", "64k", "Audio bitrate (e.g., 64k, 128k, 192k), or auto for the source bitrate")
+		sampleRate = autoIntFlag("r", 0, "Sample rate in Hz (e.g., 22050, 44100), or auto for the source rate (default 22050, 24000 for opus)")
+		channels   = autoIntFlag("c", 1, "Audio channels: 1 for mono, 2 for stereo, or auto for the source channels")
+		formatName = flag.String("f", "mp3", "Output format: mp3, m4a (aac), opus (ogg), webm, flac or wav")
+		modeName   = flag.String("mode", "", "Bitrate mode: cbr, vbr or cvbr (opus only) (default cbr, vbr for opus)")
+		copyAudio  = flag.Bool("copy", false, "Keep the source audio without re-encoding when it fits the -f container (AAC in m4a, Opus in opus or webm)")
+		quality    = flag.String("q", "", "MP3 VBR quality from V0 (best) to V9 (smallest), implies -mode vbr (default V4)")
+		start      = timeFlag("ss", "Start of the clip, such as 1:30 or 90s (default: the t= timestamp of the URL)")
+		end        = timeFlag("to", "End of the clip, such as 3:45 (default: end of the video)")
+		length     = timeFlag("t", "Length of the clip instead of -to, such as 2m")
+		loudnorm   = flag.String("loudnorm", "", "Normalize loudness in two passes: podcast (-16 LUFS), music (-14 LUFS) or a target in LUFS such as -18")
+		maxSize    = flag.String("max-size", "", "Largest output size, such as 8MB or 25MiB; picks the highest bitrate that fits")
+		presetName = flag.String("preset", "", "Quality preset: voice, standard, high, high-vbr, archive or one from -presets; other quality flags override it")
+		presetFile = flag.String("presets", os.Getenv("PRESETS_FILE"), "JSON file with user-defined presets (env PRESETS_FILE)")
+		title      = flag.String("title", "", "Title tag (default: video title)")
+		artist     = flag.String("artist", "", "Artist tag (default: channel name)")
+		album      = flag.String("album", "", "Album tag")
+		date       = flag.String("date", "", "Date tag as YYYY, YYYY-MM or YYYY-MM-DD (default: upload date)")
+		comment    = flag.String("comment", "", "Comment tag (default: video URL)")
+		cover      = flag.Bool("cover", false, "Embed the video thumbnail as cover art (not in webm or wav)")
+		square     = flag.Bool("square-cover", false, "Center-crop the cover art to a square, implies -cover")
+		chapters   = flag.String("chapters", "", "Chapters to write or split by: official, description (a tracklist in the video description) or none (default official)")
+		split      = flag.Bool("split-chapters", false, "Write one file per chapter into a directory, -o or the video title")
 		infoOnly   = flag.Bool("i", false, "Show video info only, don't download")
+		listOnly   = flag.Bool("F", false, "List the source audio formats in the order they are picked, don't download")
+		sourceName = flag.String("source", "", "Source stream to download: best, smallest or closest to -b (default best)")
+		srcCodec   = flag.String("source-codec", "", "Prefer source streams in this codec: opus or aac")
+		language   = flag.String("lang", "", "Prefer audio tracks in this language, such as en or es-419")
+
+		ytdlpPath       = flag.String("yt-dlp", os.Getenv("YTDLP_PATH"), "Path to the yt-dlp executable (env YTDLP_PATH)")
+		ffmpegPath      = flag.String("ffmpeg", os.Getenv("FFMPEG_PATH"), "Path to the ffmpeg executable (env FFMPEG_PATH)")
+		tempDir         = flag.String("tmp", os.Getenv("TEMP_DIR"), "Directory for temporary downloads (env TEMP_DIR)")
+		httpTimeout     = flag.Duration("http-timeout", envDuration("HTTP_TIMEOUT", 30*time.Second), "HTTP client timeout (env HTTP_TIMEOUT)")
+		infoTimeout     = flag.Duration("info-timeout", envDuration("INFO_TIMEOUT", 30*time.Second), "Video info lookup timeout, 0 for none (env INFO_TIMEOUT)")
+		downloadTimeout = flag.Duration("download-timeout", envDuration("DOWNLOAD_TIMEOUT", 0), "Download timeout, 0 for none (env DOWNLOAD_TIMEOUT)")
+		encodeTimeout   = flag.Duration("encode-timeout", envDuration("ENCODE_TIMEOUT", 0), "Encoding timeout, 0 for none (env ENCODE_TIMEOUT)")
+		verbose         = flag.Bool("v", false, "Log backend attempts and failures")
 	)
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] <youtube-url>\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "Download YouTube videos as MP3 audio files.\n\n")
+		fmt.Fprintf(os.Stderr, "Download YouTube videos as MP3, M4A, Opus, WebM, FLAC or WAV audio files.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  %s https://youtube.com/watch?v=...\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -o mysong.mp3 https://youtube.com/watch?v=...\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -b 128k -c 2 https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -f opus https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -preset high https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -q V2 -c 2 -r 44100 https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -f m4a -copy https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -max-size 8MB -c 2 https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -ss 1:30 -to 3:45 https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -loudnorm podcast https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -t 30s \"https://youtube.com/watch?v=...&t=95\"\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -b auto -r auto -c auto https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -F -source closest -b 128k https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -artist \"Band\" -album \"Live\" -date 2024 https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -square-cover -preset standard https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -split-chapters -f opus -o album https://youtube.com/watch?v=...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -split-chapters -chapters description https://youtube.com/watch?v=...\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -i https://youtube.com/watch?v=...\n", os.Args[0])
 	}
 	flag.Parse()
@@ -38,8 +92,169 @@ func main() {
 	}
 
 	videoURL := flag.Arg(0)
+	ref, err := mp3.ParseVideoURL(videoURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	format, err := mp3.ParseFormat(*formatName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var source mp3.SourcePreference
+	if *sourceName != "" {
+		if source, err = mp3.ParseSourcePreference(*sourceName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var mode mp3.BitrateMode
+	if *modeName != "" {
+		if mode, err = mp3.ParseBitrateMode(*modeName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var chapterSource mp3.ChapterSource
+	if *chapters != "" {
+		if chapterSource, err = mp3.ParseChapterSource(*chapters); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var loudness mp3.Loudness
+	if *loudnorm != "" {
+		if loudness, err = mp3.ParseLoudness(*loudnorm); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var maxSizeBytes int64
+	if *maxSize != "" {
+		if maxSizeBytes, err = mp3.ParseSize(*maxSize); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *end != 0 && *length != 0 {
+		fmt.Fprintln(os.Stderr, "Error: -t and -to cannot be combined")
+		os.Exit(1)
+	}
+
+	if *presetFile != "" {
+		if err := loadPresets(*presetFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	opts := &mp3.Options{}
+	if *presetName != "" {
+		preset, err := mp3.Preset(*presetName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v (available: %s)\n", err, strings.Join(mp3.Presets(), ", "))
+			os.Exit(1)
+		}
+		opts = &preset
+	}
+
+	// Flags given on the command line take precedence over the preset,
+	// the defaults of the others match the library defaults. The format
+	// comes first, it drops the preset settings it cannot take.
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "f" {
+			*opts = opts.WithFormat(format)
+		}
+	})
+	modeSet := false
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "b":
+			opts.Bitrate = *bitrate
+		case "r":
+			opts.SampleRate = int(*sampleRate)
+		case "c":
+			opts.Channels = int(*channels)
+		case "mode":
+			opts.Mode, modeSet = mode, mode != ""
+		case "q":
+			opts.Quality = *quality
+		case "ss":
+			opts.Start, opts.StartSet = time.Duration(*start), true
+		case "to":
+			opts.End = time.Duration(*end)
+		case "t":
+			// The clip starts at -ss or at the t= timestamp of the URL
+			clipStart := ref.Start
+			if opts.StartSet {
+				clipStart = opts.Start
+			}
+			opts.End = clipStart + time.Duration(*length)
+		case "loudnorm":
+			opts.Loudness = loudness
+		case "max-size":
+			opts.MaxSizeBytes = maxSizeBytes
+		case "copy":
+			opts.Copy = *copyAudio
+		case "source":
+			opts.Source.Prefer = source
+		case "source-codec":
+			opts.Source.Codec = *srcCodec
+		case "lang":
+			opts.Source.Language = *language
+		case "title":
+			opts.Metadata.Title = *title
+		case "artist":
+			opts.Metadata.Artist = *artist
+		case "album":
+			opts.Metadata.Album = *album
+		case "date":
+			opts.Metadata.Date = *date
+		case "comment":
+			opts.Metadata.Comment = *comment
+		case "chapters":
+			opts.Chapters = chapterSource
+		case "cover":
+			opts.Cover = *cover
+		case "square-cover":
+			opts.SquareCover = *square
+			opts.Cover = opts.Cover || *square
+		}
+	})
+	if *quality != "" && !modeSet {
+		opts.Mode = mp3.ModeVBR
+	}
+	opts.Progress = printProgress
+	if err := opts.Validate(); err != nil {
+		printValidationError(err)
+		os.Exit(1)
+	}
 
-	svc := mp3.New()
+	logLevel := slog.LevelError
+	if *verbose {
+		logLevel = slog.LevelDebug
+	}
+
+	svc := mp3.New(
+		mp3.WithYTDLPPath(*ytdlpPath),
+		mp3.WithFFmpegPath(*ffmpegPath),
+		mp3.WithTempDir(*tempDir),
+		mp3.WithHTTPClient(&http.Client{Timeout: *httpTimeout}),
+		mp3.WithLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))),
+		mp3.WithTimeouts(mp3.Timeouts{
+			Info:     *infoTimeout,
+			Download: *downloadTimeout,
+			Encode:   *encodeTimeout,
+		}),
+	)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -52,9 +267,9 @@ func main() {
 		cancel()
 	}()
 
-	info, err := svc.GetVideoInfo(videoURL)
+	info, err := svc.GetVideoInfo(ctx, videoURL)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting video info: %v\n", err)
+		printError("Error getting video info", err)
 		os.Exit(1)
 	}
 
@@ -63,12 +278,27 @@ func main() {
 	fmt.Printf("Duration: %s\n", info.Duration)
 
 	if *infoOnly {
+		printDetails(info)
+		return
+	}
+
+	if *listOnly {
+		fmt.Println("\nSource formats (* is downloaded):")
+		printFormats(info.CandidateFormats(opts), true)
+		return
+	}
+
+	// The conversion reuses the info instead of looking the video up again
+	opts.Info = info
+
+	if *split {
+		splitChapters(ctx, svc, videoURL, info, opts, *output)
 		return
 	}
 
 	filename := *output
 	if filename == "" {
-		filename = mp3.SanitizeFilename(info.Title) + ".mp3"
+		filename = mp3.SanitizeFilename(cmp.Or(opts.Metadata.Title, info.Title)) + cmp.Or(opts.Format, mp3.FormatMP3).Extension()
 	}
 
 	if _, err := os.Stat(filename); err == nil {
@@ -84,21 +314,214 @@ func main() {
 	defer file.Close()
 
 	fmt.Printf("Output:   %s\n", filename)
-	fmt.Printf("Bitrate:  %s, Sample Rate: %d Hz, Channels: %d\n", *bitrate, *sampleRate, *channels)
 	fmt.Println("Downloading...")
 
-	opts := &mp3.Options{
-		SampleRate: *sampleRate,
-		Channels:   *channels,
-		Bitrate:    *bitrate,
-		Format:     "mp3",
-	}
-
-	if err := svc.ConvertToWriter(ctx, videoURL, file, opts); err != nil {
+	report, err := svc.ConvertToWriter(ctx, videoURL, file, opts)
+	fmt.Println()
+	if err != nil {
 		os.Remove(filename)
-		fmt.Fprintf(os.Stderr, "Error downloading: %v\n", err)
+		printError("Error downloading", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("Done!")
+	used := report.Options
+	if report.Source.ID != "" {
+		fmt.Printf("Source:   %s\n", sourceLabel(report.Source))
+	}
+	if report.Copied {
+		fmt.Printf("Format:   %s, copied %s audio without re-encoding\n", used.Format, report.SourceCodec)
+	} else {
+		fmt.Printf("Format:   %s, Bitrate: %s, Sample Rate: %d Hz, Channels: %d\n", used.Format, bitrateLabel(used), used.SampleRate, used.Channels)
+	}
+	if used.Start > 0 || used.End > 0 {
+		clipEnd := "the end"
+		if used.End > 0 {
+			clipEnd = formatClock(used.End)
+		}
+		fmt.Printf("Clip:     %s to %s\n", formatClock(used.Start), clipEnd)
+	}
+	if l := report.Loudness; l != nil {
+		fmt.Printf("Loudness: %s, normalized to %.0f LUFS\n", loudnessLabel(l), used.Loudness.Integrated)
+	}
+	if tags := used.Metadata; tags.Title != "" {
+		fmt.Printf("Tags:     %s by %s\n", tags.Title, cmp.Or(tags.Artist, "unknown artist"))
+	}
+	if chapters := used.Metadata.Chapters; len(chapters) > 0 && used.Format.SupportsChapters() {
+		fmt.Printf("Chapters: %d\n", len(chapters))
+	}
+	if used.Cover {
+		if len(used.Metadata.Cover) > 0 {
+			fmt.Printf("Cover:    embedded, %s\n", formatBytes(int64(len(used.Metadata.Cover))))
+		} else {
+			fmt.Println("Cover:    the thumbnail could not be embedded, run with -v for details")
+		}
+	}
+	fmt.Printf("Done! (via %s)\n", report.Backend)
+}
+
+// printError prints err with one line per failed backend and a hint
+// for the most common problems.
+func printError(prefix string, err error) {
+	var backendErrs mp3.BackendErrors
+	if errors.As(err, &backendErrs) && len(backendErrs) > 0 {
+		fmt.Fprintf(os.Stderr, "%s:\n", prefix)
+		for _, be := range backendErrs {
+			fmt.Fprintf(os.Stderr, "  %s: %v\n", be.Backend, be.Err)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", prefix, err)
+	}
+
+	switch {
+	case errors.Is(err, mp3.ErrAgeRestricted):
+		fmt.Fprintln(os.Stderr, "The video is age restricted and cannot be downloaded without signing in.")
+	case errors.Is(err, mp3.ErrRegionBlocked):
+		fmt.Fprintln(os.Stderr, "The video is not available in your region.")
+	case errors.Is(err, mp3.ErrThrottled):
+		fmt.Fprintln(os.Stderr, "YouTube is blocking the download. Installing or updating yt-dlp usually helps.")
+	case errors.Is(err, mp3.ErrTooLarge):
+		fmt.Fprintln(os.Stderr, "Try a larger -max-size or a format with lower bitrates, such as -f opus.")
+	case errors.Is(err, mp3.ErrNoChapters):
+		fmt.Fprintln(os.Stderr, "Only videos with chapters can be split. Try -chapters description for a tracklist in the description, or drop -split-chapters.")
+	case errors.Is(err, mp3.ErrBackendMissing):
+		fmt.Fprintln(os.Stderr, "Make sure yt-dlp and ffmpeg are installed and on your PATH.")
+	}
+}
+
+// bitrateLabel describes the bitrate settings that were used.
+func bitrateLabel(opts mp3.Options) string {
+	switch {
+	case opts.Format.Lossless():
+		return "lossless"
+	case opts.Format == mp3.FormatMP3 && opts.Mode == mp3.ModeVBR:
+		return "VBR " + opts.Quality
+	case opts.Mode == mp3.ModeVBR, opts.Mode == mp3.ModeCVBR:
+		return fmt.Sprintf("%s ~%s", strings.ToUpper(string(opts.Mode)), opts.Bitrate)
+	default:
+		return opts.Bitrate
+	}
+}
+
+// loudnessLabel describes the measured loudness of the source.
+func loudnessLabel(l *mp3.LoudnessStats) string {
+	return fmt.Sprintf("%.1f LUFS, true peak %.1f dBTP, range %.1f LU", l.Integrated, l.TruePeak, l.Range)
+}
+
+// optionFlags maps Options fields to the flags that set them.
+var optionFlags = map[string]string{
+	"Bitrate":    "-b",
+	"SampleRate": "-r",
+	"Channels":   "-c",
+	"Format":     "-f",
+	"Mode":       "-mode",
+	"Quality":    "-q",
+
+	"MaxSizeBytes": "-max-size",
+	"Start":        "-ss",
+	"End":          "-to",
+
+	"Source.Prefer":       "-source",
+	"Metadata.Date":       "-date",
+	"Chapters":            "-chapters",
+	"Cover":               "-cover",
+	"Loudness.Integrated": "-loudnorm",
+	"Loudness.TruePeak":   "-loudnorm",
+	"Loudness.Range":      "-loudnorm",
+}
+
+// printValidationError prints one line per invalid flag, or per invalid
+// field for options no flag sets.
+func printValidationError(err error) {
+	var fieldErrs mp3.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "Error: invalid options:")
+	for _, fe := range fieldErrs {
+		fmt.Fprintf(os.Stderr, "  %s: %s\n", cmp.Or(optionFlags[fe.Field], fe.Field), fe.Message)
+	}
+}
+
+// loadPresets registers the user-defined presets in the JSON file at path.
+func loadPresets(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return mp3.LoadPresets(file)
+}
+
+// autoInt is an integer flag that also accepts "auto" for mp3.Auto.
+type autoInt int
+
+func autoIntFlag(name string, value int, usage string) *autoInt {
+	v := autoInt(value)
+	flag.Var(&v, name, usage)
+	return &v
+}
+
+func (v *autoInt) String() string {
+	if *v == mp3.Auto {
+		return "auto"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+func (v *autoInt) Set(s string) error {
+	if s == "auto" {
+		*v = mp3.Auto
+		return nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a number or auto", s)
+	}
+	*v = autoInt(n)
+	return nil
+}
+
+// clipTime is a position flag parsed with mp3.ParseTime.
+type clipTime time.Duration
+
+func timeFlag(name, usage string) *clipTime {
+	var v clipTime
+	flag.Var(&v, name, usage)
+	return &v
+}
+
+func (v *clipTime) String() string {
+	if *v == 0 {
+		return ""
+	}
+	return time.Duration(*v).String()
+}
+
+func (v *clipTime) Set(s string) error {
+	d, err := mp3.ParseTime(s)
+	if err != nil {
+		return err
+	}
+	*v = clipTime(d)
+	return nil
+}
+
+// envDuration reads a duration such as "90s" from the environment,
+// falling back to def when it is unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Warn("invalid duration in environment", "key", key, "value", v, "error", err)
+		return def
+	}
+	return d
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MateoCaicedoW/gomp3/internal/system/services/mp3"
+)
+
+const barWidth = 30
+
+// printProgress redraws a single progress line in the terminal.
+func printProgress(p mp3.Progress) {
+	downloaded := formatBytes(p.Downloaded)
+	if p.Total > 0 {
+		downloaded += " / " + formatBytes(p.Total)
+	}
+
+	var track string
+	if p.Tracks > 0 {
+		track = fmt.Sprintf("  track %d/%d", p.Track, p.Tracks)
+	}
+
+	percent := p.Percent()
+	if percent < 0 {
+		fmt.Printf("\r%s  %s encoded%s\033[K", downloaded, formatClock(p.Encoded), track)
+		return
+	}
+
+	filled := int(percent / 100 * barWidth)
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled)
+
+	position := formatClock(p.Encoded)
+	if p.Duration > 0 {
+		position += " / " + formatClock(p.Duration)
+	}
+
+	fmt.Printf("\r[%s] %5.1f%%  %s  %s%s\033[K", bar, percent, downloaded, position, track)
+}
+
+// formatBytes formats a byte count in the decimal units -max-size reads,
+// such as "3.2 MB".
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1f GB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1f MB", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1f KB", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// formatClock formats a position in the audio as "mm:ss", or "h:mm:ss"
+// from an hour on.
+func formatClock(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/MateoCaicedoW/gomp3/internal/system/services/mp3"
+)
+
+// splitChapters writes one file per chapter of the video into dir, or
+// into a directory named after the video when dir is empty.
+func splitChapters(ctx context.Context, svc *mp3.Service, videoURL string, info *mp3.VideoInfo, opts *mp3.Options, dir string) {
+	if len(info.ChaptersFrom(opts.Chapters)) == 0 {
+		printError("Error", fmt.Errorf("%w: %s", mp3.ErrNoChapters, videoURL))
+		os.Exit(1)
+	}
+
+	if dir == "" {
+		dir = mp3.SanitizeFilename(cmp.Or(opts.Metadata.Title, info.Title))
+	}
+	if _, err := os.Stat(dir); err == nil {
+		fmt.Fprintf(os.Stderr, "Error: '%s' already exists\n", dir)
+		os.Exit(1)
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Output:   %s%c\n", dir, filepath.Separator)
+	fmt.Println("Downloading...")
+
+	var tracks []mp3.Track
+	reports, err := svc.SplitChapters(ctx, videoURL, opts, func(track mp3.Track) (io.WriteCloser, error) {
+		tracks = append(tracks, track)
+		return os.Create(filepath.Join(dir, track.Filename))
+	})
+	fmt.Println()
+	if err != nil {
+		os.RemoveAll(dir)
+		printError("Error splitting", err)
+		os.Exit(1)
+	}
+
+	used := reports[0].Options
+	if reports[0].Copied {
+		fmt.Printf("Format:   %s, copied %s audio without re-encoding\n", used.Format, reports[0].SourceCodec)
+	} else {
+		fmt.Printf("Format:   %s, Bitrate: %s, Sample Rate: %d Hz, Channels: %d\n", used.Format, bitrateLabel(used), used.SampleRate, used.Channels)
+	}
+	for _, track := range tracks {
+		fmt.Printf("  %s  %s\n", formatClock(track.Chapter.End-track.Chapter.Start), track.Filename)
+	}
+	fmt.Printf("Done! %d tracks (via %s)\n", len(reports), reports[0].Backend)
+}
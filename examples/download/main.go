@@ -20,7 +20,7 @@ func main() {
 	svc := mp3.New()
 
 	// Get video info
-	info, err := svc.GetVideoInfo(videoURL)
+	info, err := svc.GetVideoInfo(context.Background(), videoURL)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting video info: %v\n", err)
 		os.Exit(1)
@@ -42,7 +42,7 @@ func main() {
 
 	// Download and convert
 	opts := mp3.DefaultOptions()
-	if err := svc.ConvertToWriter(context.Background(), videoURL, file, opts); err != nil {
+	if _, err := svc.ConvertToWriter(context.Background(), videoURL, file, opts); err != nil {
 		fmt.Fprintf(os.Stderr, "Error converting: %v\n", err)
 		os.Exit(1)
 	}
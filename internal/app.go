@@ -38,6 +38,8 @@ func New() (http.Handler, string) {
 	// Defining the routes in the application.
 	r.HandleFunc("GET /{$}", converter.Index)
 	r.HandleFunc("POST /convert", converter.Convert)
+	r.HandleFunc("GET /progress/{id}", converter.ConvertProgress)
+	r.HandleFunc("GET /preview", converter.Preview)
 
 	r.Folder(assets.Manager.HandlerPattern(), assets.Manager)
 	return r.Handler(), r.Addr()
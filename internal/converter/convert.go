@@ -1,8 +1,15 @@
 package converter
 
 import (
+	"archive/zip"
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/MateoCaicedoW/gomp3/internal/system/services/mp3"
 	"go.leapkit.dev/core/server"
@@ -20,25 +27,264 @@ func Convert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get video info first for the filename
-	svc := mp3.New()
-	info, err := svc.GetVideoInfo(videoURL)
+	// Reject anything that is not a YouTube video before calling the backends
+	if _, err := mp3.ParseVideoURL(videoURL); err != nil {
+		server.Errorf(w, http.StatusBadRequest, "%w", err)
+		return
+	}
+
+	// Unknown formats and invalid encoder settings are rejected before
+	// anything is downloaded
+	opts, err := formOptions(r)
+	if err != nil {
+		server.Errorf(w, http.StatusBadRequest, "%w", err)
+		return
+	}
+	format := opts.Format
+
+	// Get video info first for the filename, the conversion reuses it
+	info, err := svc.GetVideoInfo(r.Context(), videoURL)
 	if err != nil {
-		server.Errorf(w, http.StatusInternalServerError, "failed to get video info: %w", err)
+		server.Errorf(w, statusCode(err), "%w", err)
+		return
+	}
+	opts.Info = info
+
+	// Splitting by chapter sends a ZIP archive with one file per chapter
+	split := r.FormValue("split") == "on"
+	if split && len(info.ChaptersFrom(opts.Chapters)) == 0 {
+		server.Errorf(w, http.StatusUnprocessableEntity, "%w: %s", mp3.ErrNoChapters, videoURL)
 		return
 	}
 
-	sanitizedTitle := mp3.SanitizeFilename(info.Title)
+	filename := mp3.SanitizeFilename(cmp.Or(opts.Metadata.Title, info.Title))
+	contentType := format.MIMEType()
+	if split {
+		filename, contentType = filename+".zip", "application/zip"
+	} else {
+		filename += format.Extension()
+	}
 
 	// Set headers before starting conversion
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.mp3\"", sanitizedTitle))
-	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Content-Transfer-Encoding", "binary")
 	w.Header().Set("Cache-Control", "no-cache")
 
+	if id := r.FormValue("progress-id"); id != "" && len(id) <= 64 {
+		job := acquireJob(id)
+		defer releaseJob(id)
+		defer job.finish()
+
+		opts.Progress = job.publish
+	}
+
+	if split {
+		splitChapters(w, r, videoURL, opts)
+		return
+	}
+
 	// Stream directly to response writer using the service
-	if err := svc.ConvertToWriter(r.Context(), videoURL, w, nil); err != nil {
-		server.Errorf(w, http.StatusInternalServerError, "conversion failed: %w", err)
+	report, err := svc.ConvertToWriter(r.Context(), videoURL, w, opts)
+	if errors.Is(err, mp3.ErrPartialOutput) {
+		// The response is already streaming, abort it so the client
+		// does not keep a truncated file.
+		slog.Error("conversion failed mid-stream", "url", videoURL, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	if err != nil {
+		server.Errorf(w, statusCode(err), "conversion failed: %w", err)
+		return
+	}
+
+	attrs := []any{
+		"url", videoURL,
+		"backend", report.Backend,
+		"bytes", report.Bytes,
+		"copied", report.Copied,
+		"bitrate", report.Options.Bitrate,
+		"sample_rate", report.Options.SampleRate,
+		"channels", report.Options.Channels,
+	}
+	if report.Loudness != nil {
+		attrs = append(attrs, "source_lufs", report.Loudness.Integrated, "source_true_peak", report.Loudness.TruePeak)
+	}
+	slog.Info("converted video", attrs...)
+}
+
+// splitChapters streams one file per chapter of the video as a ZIP
+// archive. The audio is already compressed, so the entries are stored.
+func splitChapters(w http.ResponseWriter, r *http.Request, videoURL string, opts *mp3.Options) {
+	archive := zip.NewWriter(w)
+	started := false
+
+	reports, err := svc.SplitChapters(r.Context(), videoURL, opts, func(track mp3.Track) (io.WriteCloser, error) {
+		started = true
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     track.Filename,
+			Method:   zip.Store,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nopCloser{entry}, nil
+	})
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil && started {
+		// Part of the archive was sent, abort the response so the client
+		// does not keep a truncated file.
+		slog.Error("splitting failed mid-stream", "url", videoURL, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	if err != nil {
+		server.Errorf(w, statusCode(err), "splitting failed: %w", err)
 		return
 	}
+
+	var bytes int64
+	for _, report := range reports {
+		bytes += report.Bytes
+	}
+	slog.Info("split video by chapter",
+		"url", videoURL,
+		"backend", reports[0].Backend,
+		"tracks", len(reports),
+		"bytes", bytes,
+		"copied", reports[0].Copied,
+		"bitrate", reports[0].Options.Bitrate,
+	)
+}
+
+// nopCloser turns a ZIP entry into the io.WriteCloser SplitChapters
+// expects. Entries are finished by the next entry or by closing the
+// archive.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// formOptions reads the conversion options from the form, starting from
+// the chosen preset, and validates them against the chosen format.
+func formOptions(r *http.Request) (*mp3.Options, error) {
+	opts, err := mp3.Preset(cmp.Or(r.FormValue("preset"), mp3.PresetVoice))
+	if err != nil {
+		return nil, err
+	}
+
+	if v := r.FormValue("format"); v != "" {
+		format, err := mp3.ParseFormat(v)
+		if err != nil {
+			return nil, err
+		}
+		// Drop the preset settings the format cannot take
+		opts = opts.WithFormat(format)
+	}
+	if v := r.FormValue("bitrate"); v != "" {
+		opts.Bitrate = v
+	}
+	if v := r.FormValue("quality"); v != "" {
+		// A VBR level only makes sense in VBR mode
+		opts.Quality, opts.Mode = v, mp3.ModeVBR
+	}
+	if v := r.FormValue("mode"); v != "" {
+		if opts.Mode, err = mp3.ParseBitrateMode(v); err != nil {
+			return nil, err
+		}
+	}
+	opts.Metadata = mp3.Metadata{
+		Title:   r.FormValue("title"),
+		Artist:  r.FormValue("artist"),
+		Album:   r.FormValue("album"),
+		Date:    r.FormValue("date"),
+		Comment: r.FormValue("comment"),
+	}
+	if v := r.FormValue("chapters"); v != "" {
+		if opts.Chapters, err = mp3.ParseChapterSource(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := r.FormValue("start"); v != "" {
+		if opts.Start, err = mp3.ParseTime(v); err != nil {
+			return nil, fmt.Errorf("invalid start: %w", err)
+		}
+		opts.StartSet = true
+	}
+	if v := r.FormValue("end"); v != "" {
+		if opts.End, err = mp3.ParseTime(v); err != nil {
+			return nil, fmt.Errorf("invalid end: %w", err)
+		}
+	}
+	if v := r.FormValue("loudness"); v != "" {
+		if opts.Loudness, err = mp3.ParseLoudness(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := r.FormValue("max-size"); v != "" {
+		if opts.MaxSizeBytes, err = mp3.ParseSize(v); err != nil {
+			return nil, err
+		}
+	}
+	if r.FormValue("square-cover") == "on" {
+		opts.Cover, opts.SquareCover = true, true
+	} else if r.FormValue("cover") == "on" {
+		opts.Cover = true
+	}
+	if r.FormValue("copy") == "on" {
+		opts.Copy = true
+	}
+	if v := r.FormValue("sample-rate"); v != "" {
+		if opts.SampleRate, err = autoInt(v); err != nil {
+			return nil, fmt.Errorf("invalid sample-rate %q", v)
+		}
+	}
+	if v := r.FormValue("channels"); v != "" {
+		if opts.Channels, err = autoInt(v); err != nil {
+			return nil, fmt.Errorf("invalid channels %q", v)
+		}
+	}
+	if r.FormValue("auto") == "on" {
+		// Match the source instead of upsampling it
+		opts.Bitrate, opts.SampleRate, opts.Channels = mp3.AutoBitrate, mp3.Auto, mp3.Auto
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return &opts, nil
+}
+
+// autoInt parses a number or "auto" for mp3.Auto.
+func autoInt(v string) (int, error) {
+	if v == "auto" {
+		return mp3.Auto, nil
+	}
+	return strconv.Atoi(v)
+}
+
+// statusCode picks the HTTP status for a service error. Problems with the
+// video itself take precedence over problems with the backends.
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, mp3.ErrInvalidURL), errors.Is(err, mp3.ErrURLNotAllowed), errors.Is(err, mp3.ErrUnsupportedFormat),
+		errors.Is(err, mp3.ErrInvalidOptions), errors.Is(err, mp3.ErrUnknownPreset):
+		return http.StatusBadRequest
+	case errors.Is(err, mp3.ErrTooLarge), errors.Is(err, mp3.ErrNoChapters):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, mp3.ErrVideoUnavailable):
+		return http.StatusNotFound
+	case errors.Is(err, mp3.ErrAgeRestricted):
+		return http.StatusForbidden
+	case errors.Is(err, mp3.ErrRegionBlocked):
+		return http.StatusUnavailableForLegalReasons
+	case errors.Is(err, mp3.ErrThrottled):
+		return http.StatusBadGateway
+	case errors.Is(err, mp3.ErrBackendMissing), errors.Is(err, mp3.ErrNoExtractors):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
 }
@@ -1,6 +1,12 @@
 package converter
 
 import (
+	"cmp"
+	"fmt"
+	"strings"
+
+	"github.com/MateoCaicedoW/gomp3/internal/system/services/mp3"
+
 	lucide "github.com/eduardolat/gomponents-lucide"
 	"github.com/wawandco/gomui"
 	. "maragu.dev/gomponents"
@@ -41,39 +47,145 @@ func indexEl() Node {
 
 			// Input Section
 			Form(
+				ID("convert-form"),
 				hx.Post("/convert"),
 				hx.Indicator("#loading-indicator"),
 				hx.DisabledElt("#convert-button"),
 				hx.Ext("htmx-download"),
-				Class("w-full flex flex-col sm:flex-row gap-3 p-2 rounded-xl border"),
+				hx.Disinherit("*"),
+				Class("w-full flex flex-col gap-3 p-2 rounded-xl border"),
 				Div(
-					Class("relative w-full"),
-					gomui.InputWithClasses(
-						"w-full flex-1 h-full bg-transparent border-none focus:ring-0 pl-10 text-base font-medium shadow-none",
-						Type("text"),
-						Placeholder("Paste YouTube URL here..."),
-						AutoFocus(),
-						Name("youtube-url"),
+					Class("w-full flex flex-col sm:flex-row gap-3"),
+					Div(
+						Class("relative w-full"),
+						gomui.InputWithClasses(
+							"w-full flex-1 h-full bg-transparent border-none focus:ring-0 pl-10 text-base font-medium shadow-none",
+							Type("text"),
+							Placeholder("Paste YouTube URL here..."),
+							AutoFocus(),
+							Name("youtube-url"),
+							hx.Get("/preview"),
+							hx.Trigger("input changed delay:500ms"),
+							hx.Target("#preview"),
+						),
+
+						lucide.Link(Class("size-4 absolute top-1/2 -translate-y-1/2 left-3 ")),
+					),
+
+					gomui.Select(
+						presetOptions(),
+						Name("preset"),
+						Aria("label", "Quality preset"),
+					),
+
+					gomui.Select(
+						formatOptions(),
+						Name("format"),
+						Aria("label", "Output format"),
 					),
 
-					lucide.Link(Class("size-4 absolute top-1/2 -translate-y-1/2 left-3 ")),
+					gomui.ButtonWithClasses(
+						" shrink-0 px-12 md:h-14 w-full sm:w-auto flex items-center justify-center gap-2",
+						gomui.ButtonPrimary,
+						gomui.ButtonLg,
+						true,
+						ID("convert-button"),
+						Type("submit"),
+						P(
+							Class("font-medium text-lg"),
+							Text("Convert"),
+						),
+						lucide.ArrowRight(Class("size-5")),
+					),
 				),
 
-				gomui.ButtonWithClasses(
-					" shrink-0 px-12 md:h-14 w-full sm:w-auto flex items-center justify-center gap-2",
-					gomui.ButtonPrimary,
-					gomui.ButtonLg,
-					true,
-					ID("convert-button"),
-					Type("submit"),
-					P(
-						Class("font-medium text-lg"),
-						Text("Convert"),
+				// Settings that override the chosen preset
+				Details(
+					Class("px-2 pb-1"),
+					Summary(
+						Class("cursor-pointer text-sm text-muted-foreground"),
+						Text("Advanced options"),
+					),
+					Div(
+						Class("grid grid-cols-1 sm:grid-cols-2 gap-3 pt-3"),
+						gomui.InputWithClasses(
+							"sm:col-span-2",
+							Type("text"),
+							Name("bitrate"),
+							Placeholder("Bitrate, e.g. 128k"),
+							Aria("label", "Bitrate"),
+						),
+						gomui.Select(
+							sampleRateOptions(),
+							Name("sample-rate"),
+							Aria("label", "Sample rate"),
+						),
+						gomui.Select(
+							channelOptions(),
+							Name("channels"),
+							Aria("label", "Channels"),
+						),
+						gomui.Select(
+							modeOptions(),
+							Name("mode"),
+							Aria("label", "Bitrate mode"),
+						),
+						gomui.Select(
+							qualityOptions(),
+							Name("quality"),
+							Aria("label", "MP3 VBR quality"),
+						),
+						Div(
+							Class("sm:col-span-2 grid"),
+							gomui.Select(
+								loudnessOptions(),
+								Name("loudness"),
+								Aria("label", "Loudness normalization"),
+							),
+						),
+						gomui.InputWithClasses(
+							"sm:col-span-2",
+							Type("text"),
+							Name("max-size"),
+							Placeholder("Max file size, e.g. 8MB"),
+							Aria("label", "Maximum file size"),
+						),
+						gomui.InputEl(
+							Type("text"),
+							Name("start"),
+							Placeholder("Start, e.g. 1:30 (default: t= in the link)"),
+							Aria("label", "Clip start"),
+						),
+						gomui.InputEl(
+							Type("text"),
+							Name("end"),
+							Placeholder("End, e.g. 3:45"),
+							Aria("label", "Clip end"),
+						),
+						Label(
+							Class("flex items-center gap-2 text-sm sm:col-span-2"),
+							Input(Type("checkbox"), Name("auto"), Value("on")),
+							Text("Match the source bitrate, sample rate and channels, never upsampling them"),
+						),
+						Label(
+							Class("flex items-center gap-2 text-sm sm:col-span-2"),
+							Input(Type("checkbox"), Name("copy"), Value("on")),
+							Text("Keep the original audio when it fits the format (AAC in M4A, Opus in OGG or WebM)"),
+						),
+						Label(
+							Class("flex items-center gap-2 text-sm sm:col-span-2"),
+							Input(Type("checkbox"), Name("split"), Value("on")),
+							Text("Split into one file per chapter, downloaded as a ZIP"),
+						),
 					),
-					lucide.ArrowRight(Class("size-5")),
 				),
 			),
 
+			Div(
+				ID("preview"),
+				Class("w-full"),
+			),
+
 			gomui.CardWithClasses(
 				"w-full loading-indicator",
 				ID("loading-indicator"),
@@ -88,16 +200,183 @@ func indexEl() Node {
 							Text("Converting Video to MP3..."),
 						),
 					),
+					P(
+						ID("progress-text"),
+						Class("text-sm text-muted-foreground"),
+					),
+				),
+				Div(
+					Class("mx-6 mb-4 h-2 rounded-full bg-muted overflow-hidden"),
+					Div(
+						ID("progress-bar"),
+						Class("h-full w-0 bg-primary transition-all"),
+					),
 				),
 			),
 		),
 
 		Script(Raw(
-			`document.addEventListener('htmx:afterRequest', (event) => {
+			`let progressSource;
+
+			document.addEventListener('htmx:configRequest', (event) => {
+				if (event.detail.elt.tagName !== 'FORM') {
+					return;
+				}
+
+				const id = Math.random().toString(36).slice(2) + Date.now().toString(36);
+				event.detail.parameters['progress-id'] = id;
+
+				progressSource = new EventSource('/progress/' + id);
+				progressSource.onmessage = (e) => {
+					const progress = JSON.parse(e.data);
+					document.getElementById('progress-text').textContent = progress.text;
+					document.getElementById('progress-bar').style.width = Math.max(progress.percent, 0) + '%';
+				};
+				progressSource.addEventListener('done', () => progressSource.close());
+			})
+
+			document.addEventListener('htmx:afterRequest', (event) => {
+				if (event.detail.elt.tagName !== 'FORM') {
+					return;
+				}
+
+				if (progressSource) {
+					progressSource.close();
+				}
+				document.getElementById('progress-text').textContent = '';
+				document.getElementById('progress-bar').style.width = '0';
+
 				if (event.detail.xhr.status === 200) {
 					document.querySelector('form').reset();
+					document.getElementById('preview').innerHTML = '';
 				}
 			})`,
 		)),
 	)
 }
+
+// formatLabels are the names shown in the format picker.
+var formatLabels = map[mp3.Format]string{
+	mp3.FormatMP3:  "MP3",
+	mp3.FormatM4A:  "AAC (M4A)",
+	mp3.FormatOpus: "Opus (OGG)",
+	mp3.FormatWebM: "Opus (WebM)",
+	mp3.FormatFLAC: "FLAC",
+	mp3.FormatWAV:  "WAV",
+}
+
+// formatOptions lists the output formats, the first option keeps the
+// format of the chosen preset.
+func formatOptions() []gomui.SelectOption {
+	opts := []gomui.SelectOption{
+		{Value: "", Label: "Preset format", Selected: true},
+	}
+	for _, f := range mp3.Formats() {
+		opts = append(opts, gomui.SelectOption{
+			Value: string(f),
+			Label: formatLabels[f],
+		})
+	}
+	return opts
+}
+
+// presetOptions lists the built-in and user-defined presets.
+func presetOptions() []gomui.SelectOption {
+	var opts []gomui.SelectOption
+	for _, name := range mp3.Presets() {
+		preset, err := mp3.Preset(name)
+		if err != nil {
+			continue
+		}
+
+		opts = append(opts, gomui.SelectOption{
+			Value:    name,
+			Label:    presetLabel(name, preset),
+			Selected: name == mp3.PresetVoice,
+		})
+	}
+	return opts
+}
+
+// modeOptions lists the bitrate modes, the first option keeps the mode
+// of the chosen preset.
+func modeOptions() []gomui.SelectOption {
+	return []gomui.SelectOption{
+		{Value: "", Label: "Preset bitrate mode", Selected: true},
+		{Value: string(mp3.ModeCBR), Label: "Constant bitrate"},
+		{Value: string(mp3.ModeVBR), Label: "Variable bitrate"},
+		{Value: string(mp3.ModeCVBR), Label: "Constrained VBR (Opus)"},
+	}
+}
+
+// sampleRateOptions lists the common output sample rates.
+func sampleRateOptions() []gomui.SelectOption {
+	return []gomui.SelectOption{
+		{Value: "", Label: "Preset sample rate", Selected: true},
+		{Value: "22050", Label: "22.05 kHz"},
+		{Value: "24000", Label: "24 kHz"},
+		{Value: "44100", Label: "44.1 kHz"},
+		{Value: "48000", Label: "48 kHz"},
+	}
+}
+
+// channelOptions lists mono and stereo output.
+func channelOptions() []gomui.SelectOption {
+	return []gomui.SelectOption{
+		{Value: "", Label: "Preset channels", Selected: true},
+		{Value: "1", Label: "Mono"},
+		{Value: "2", Label: "Stereo"},
+	}
+}
+
+// loudnessOptions lists the loudness normalization presets.
+func loudnessOptions() []gomui.SelectOption {
+	return []gomui.SelectOption{
+		{Value: "", Label: "Keep the original loudness", Selected: true},
+		{Value: mp3.LoudnessPodcast, Label: "Normalize for podcasts (-16 LUFS)"},
+		{Value: mp3.LoudnessMusic, Label: "Normalize for music (-14 LUFS)"},
+	}
+}
+
+// qualityOptions lists the LAME VBR levels, picking one switches MP3
+// output to variable bitrate.
+func qualityOptions() []gomui.SelectOption {
+	opts := []gomui.SelectOption{
+		{Value: "", Label: "Preset VBR quality", Selected: true},
+	}
+	for level := range 10 {
+		label := fmt.Sprintf("MP3 VBR V%d", level)
+		switch level {
+		case 0:
+			label += " (best)"
+		case 9:
+			label += " (smallest)"
+		}
+
+		opts = append(opts, gomui.SelectOption{Value: fmt.Sprintf("V%d", level), Label: label})
+	}
+	return opts
+}
+
+// presetLabel describes a preset, such as "high (MP3 320k stereo)".
+func presetLabel(name string, preset mp3.Options) string {
+	format := cmp.Or(preset.Format, mp3.FormatMP3)
+
+	details := []string{formatLabels[format]}
+	switch {
+	case format.Lossless():
+		// No bitrate to show
+	case preset.Mode == mp3.ModeVBR && format == mp3.FormatMP3:
+		details = append(details, preset.Quality)
+	case preset.Bitrate != "":
+		details = append(details, preset.Bitrate)
+	}
+	switch preset.Channels {
+	case 1:
+		details = append(details, "mono")
+	case 2:
+		details = append(details, "stereo")
+	}
+
+	return fmt.Sprintf("%s (%s)", name, strings.Join(details, " "))
+}
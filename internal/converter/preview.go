@@ -0,0 +1,32 @@
+package converter
+
+import (
+	"net/http"
+
+	"github.com/MateoCaicedoW/gomp3/internal/system/services/mp3"
+	"go.leapkit.dev/core/server"
+	. "maragu.dev/gomponents"
+)
+
+// Preview renders the details of the video pasted in the form so users
+// can check it before converting.
+func Preview(w http.ResponseWriter, r *http.Request) {
+	videoURL := r.FormValue("youtube-url")
+	if videoURL == "" {
+		return
+	}
+
+	var el Node
+	if _, err := mp3.ParseVideoURL(videoURL); err != nil {
+		el = previewErrorEl(err.Error())
+	} else if info, err := svc.GetVideoInfo(r.Context(), videoURL); err != nil {
+		el = previewErrorEl("Could not find that video. Check the URL and try again.")
+	} else {
+		el = previewEl(info)
+	}
+
+	if err := el.Render(w); err != nil {
+		server.Errorf(w, http.StatusInternalServerError, "error rendering preview %w", err)
+		return
+	}
+}
@@ -0,0 +1,157 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MateoCaicedoW/gomp3/internal/system/services/mp3"
+
+	lucide "github.com/eduardolat/gomponents-lucide"
+	"github.com/wawandco/gomui"
+	. "maragu.dev/gomponents"
+	. "maragu.dev/gomponents/html"
+)
+
+func previewEl(info *mp3.VideoInfo) Node {
+	// The thumbnail shown is the one embedded as cover art
+	thumb, hasThumb := info.CoverThumbnail()
+
+	return gomui.CardWithClasses(
+		"w-full",
+		gomui.CardContent(
+			Class("flex flex-col sm:flex-row gap-4"),
+			If(hasThumb,
+				Div(
+					Class("group flex flex-col gap-2 w-full sm:w-48 shrink-0"),
+					Img(
+						Src(thumb.URL),
+						Alt(info.Title),
+						// object-cover crops the center, like the square cover
+						Class("w-full aspect-video object-cover rounded-lg group-has-[[name=square-cover]:checked]:aspect-square"),
+					),
+					coverCheckbox("cover", "Embed as cover art"),
+					coverCheckbox("square-cover", "Crop to square"),
+				),
+			),
+			Div(
+				Class("flex flex-col gap-2 min-w-0"),
+				H3(Class("font-bold text-lg leading-tight"), Text(info.Title)),
+				P(Class("text-sm text-muted-foreground"), Text(info.Author)),
+				Div(
+					Class("flex flex-wrap items-center gap-3 text-xs text-muted-foreground"),
+					Span(Class("flex items-center gap-1"), lucide.Clock(Class("size-3")), Text(formatClock(info.Duration))),
+					Span(Class("flex items-center gap-1"), lucide.Eye(Class("size-3")), Text(fmt.Sprintf("%d views", info.Views))),
+					If(!info.PublishDate.IsZero(),
+						Span(Class("flex items-center gap-1"), lucide.Calendar(Class("size-3")), Text(info.PublishDate.Format("Jan 2, 2006"))),
+					),
+					If(len(info.Chapters) > 0,
+						Span(Class("flex items-center gap-1"), lucide.ListOrdered(Class("size-3")), Text(fmt.Sprintf("%d chapters", len(info.Chapters)))),
+					),
+				),
+				If(info.Description != "",
+					P(Class("text-sm line-clamp-3 whitespace-pre-line"), Text(info.Description)),
+				),
+				If(len(info.AudioFormats) > 0,
+					Div(
+						Class("flex flex-wrap gap-1"),
+						Map(info.AudioFormats, func(f mp3.AudioFormat) Node {
+							return gomui.Badge(gomui.BadgeSecondary, Text(audioFormatLabel(f)))
+						}),
+					),
+				),
+				chaptersEl(info),
+				tagsEl(info),
+			),
+		),
+	)
+}
+
+// coverCheckbox renders a cover art option of the convert form.
+func coverCheckbox(name, label string) Node {
+	return Label(
+		Class("flex items-center gap-2 text-sm"),
+		Input(Type("checkbox"), FormAttr("convert-form"), Name(name), Value("on")),
+		Text(label),
+	)
+}
+
+// chaptersEl lets the user pick between the chapters of the video and the
+// tracklist in its description, it renders nothing when it has neither.
+// The description is preselected for videos without chapters.
+func chaptersEl(info *mp3.VideoInfo) Node {
+	official := len(info.Chapters)
+	described := len(info.ChaptersFrom(mp3.ChaptersDescription))
+	if official == 0 && described == 0 {
+		return nil
+	}
+
+	var options []gomui.SelectOption
+	if official > 0 {
+		options = append(options, gomui.SelectOption{Value: string(mp3.ChaptersOfficial), Label: fmt.Sprintf("YouTube chapters (%d)", official), Selected: true})
+	}
+	if described > 0 {
+		options = append(options, gomui.SelectOption{Value: string(mp3.ChaptersDescription), Label: fmt.Sprintf("Tracklist in the description (%d)", described), Selected: official == 0})
+	}
+	options = append(options, gomui.SelectOption{Value: string(mp3.ChaptersNone), Label: "No chapters"})
+
+	return gomui.Select(
+		options,
+		FormAttr("convert-form"),
+		Name("chapters"),
+		Aria("label", "Chapters"),
+	)
+}
+
+// tagsEl renders the tags written to the file, prefilled from the video.
+// The fields belong to the convert form even though the preview is
+// rendered outside of it.
+func tagsEl(info *mp3.VideoInfo) Node {
+	var date string
+	if !info.PublishDate.IsZero() {
+		date = info.PublishDate.Format("2006-01-02")
+	}
+
+	field := func(name, label, value string) Node {
+		return gomui.InputEl(
+			Type("text"),
+			FormAttr("convert-form"),
+			Name(name),
+			Value(value),
+			Placeholder(label),
+			Aria("label", label),
+		)
+	}
+
+	return Details(
+		Summary(
+			Class("cursor-pointer text-sm text-muted-foreground"),
+			Text("Edit tags"),
+		),
+		Div(
+			Class("grid grid-cols-1 sm:grid-cols-2 gap-2 pt-2"),
+			field("title", "Title", info.Title),
+			field("artist", "Artist", strings.TrimSuffix(info.Author, " - Topic")),
+			field("album", "Album", ""),
+			field("date", "Date (YYYY-MM-DD)", date),
+			Div(Class("sm:col-span-2"), field("comment", "Comment (default: video URL)", "")),
+		),
+	)
+}
+
+func previewErrorEl(message string) Node {
+	return P(
+		Class("text-sm text-destructive text-center"),
+		Text(message),
+	)
+}
+
+func audioFormatLabel(f mp3.AudioFormat) string {
+	label := f.Codec
+	if f.Bitrate > 0 {
+		label += fmt.Sprintf(" %dk", f.Bitrate/1000)
+	}
+	if f.Language != "" {
+		label += " " + f.Language
+	}
+	return label
+}
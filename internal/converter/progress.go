@@ -0,0 +1,167 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MateoCaicedoW/gomp3/internal/system/services/mp3"
+	"go.leapkit.dev/core/server"
+)
+
+// progressJob holds the latest progress of a conversion. The browser
+// subscribes to it through ConvertProgress while Convert publishes to it.
+type progressJob struct {
+	mu      sync.Mutex
+	refs    int
+	latest  mp3.Progress
+	started bool
+	done    bool
+	changed chan struct{}
+}
+
+var (
+	jobsMu sync.Mutex
+	jobs   = map[string]*progressJob{}
+)
+
+// acquireJob returns the job with the given id, creating it when needed.
+// Either side may arrive first, so both acquire and release the job.
+func acquireJob(id string) *progressJob {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	j, ok := jobs[id]
+	if !ok {
+		j = &progressJob{changed: make(chan struct{})}
+		jobs[id] = j
+	}
+	j.refs++
+	return j
+}
+
+func releaseJob(id string) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	if j, ok := jobs[id]; ok {
+		j.refs--
+		if j.refs <= 0 {
+			delete(jobs, id)
+		}
+	}
+}
+
+func (j *progressJob) publish(p mp3.Progress) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.latest = p
+	j.started = true
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *progressJob) finish() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.done = true
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *progressJob) snapshot() (mp3.Progress, bool, bool, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.latest, j.started, j.done, j.changed
+}
+
+// ConvertProgress streams the progress of a conversion as server-sent events.
+// The id is generated by the browser and sent along with the convert form.
+func ConvertProgress(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" || len(id) > 64 {
+		server.Errorf(w, http.StatusBadRequest, "invalid progress id")
+		return
+	}
+
+	job := acquireJob(id)
+	defer releaseJob(id)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+
+	for {
+		p, started, done, changed := job.snapshot()
+		if started {
+			data, _ := json.Marshal(progressEvent(p))
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+		if done {
+			fmt.Fprint(w, "event: done\ndata: {}\n\n")
+			rc.Flush()
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+type progressData struct {
+	Percent float64 `json:"percent"`
+	Text    string  `json:"text"`
+}
+
+func progressEvent(p mp3.Progress) progressData {
+	text := "Downloading " + formatBytes(p.Downloaded)
+	if p.Total > 0 {
+		text += " of " + formatBytes(p.Total)
+	}
+	if p.Encoded > 0 {
+		text = "Encoding " + formatClock(p.Encoded)
+		if p.Duration > 0 {
+			text += " of " + formatClock(p.Duration)
+		}
+		if p.Tracks > 0 {
+			text += fmt.Sprintf(", track %d of %d", p.Track, p.Tracks)
+		}
+	}
+
+	return progressData{Percent: p.Percent(), Text: text}
+}
+
+// formatBytes formats a byte count in the decimal units the max-size field
+// reads, such as "3.2 MB".
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1f GB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1f MB", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1f KB", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// formatClock formats a position in the audio as "mm:ss", or "h:mm:ss"
+// from an hour on.
+func formatClock(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
@@ -0,0 +1,71 @@
+package converter
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/MateoCaicedoW/gomp3/internal/system/services/mp3"
+)
+
+var (
+	// Conversion settings loaded from the environment
+	ytdlpPath       = os.Getenv("YTDLP_PATH")
+	ffmpegPath      = os.Getenv("FFMPEG_PATH")
+	tempDir         = os.Getenv("TEMP_DIR")
+	httpTimeout     = envDuration("HTTP_TIMEOUT", 30*time.Second)
+	infoTimeout     = envDuration("INFO_TIMEOUT", 30*time.Second)
+	downloadTimeout = envDuration("DOWNLOAD_TIMEOUT", 0)
+	encodeTimeout   = envDuration("ENCODE_TIMEOUT", 0)
+	presetsFile     = os.Getenv("PRESETS_FILE")
+
+	// svc is shared by all handlers, it is safe for concurrent use.
+	svc = mp3.New(
+		mp3.WithYTDLPPath(ytdlpPath),
+		mp3.WithFFmpegPath(ffmpegPath),
+		mp3.WithTempDir(tempDir),
+		mp3.WithHTTPClient(&http.Client{Timeout: httpTimeout}),
+		mp3.WithLogger(slog.Default()),
+		mp3.WithTimeouts(mp3.Timeouts{
+			Info:     infoTimeout,
+			Download: downloadTimeout,
+			Encode:   encodeTimeout,
+		}),
+	)
+)
+
+func init() {
+	if presetsFile == "" {
+		return
+	}
+
+	// User-defined presets show up in the preset dropdown next to the
+	// built-in ones
+	file, err := os.Open(presetsFile)
+	if err != nil {
+		slog.Error("failed to open presets file", "path", presetsFile, "error", err)
+		return
+	}
+	defer file.Close()
+
+	if err := mp3.LoadPresets(file); err != nil {
+		slog.Error("failed to load presets", "path", presetsFile, "error", err)
+	}
+}
+
+// envDuration reads a duration such as "90s" from the environment,
+// falling back to def when it is unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Warn("invalid duration in environment", "key", key, "value", v, "error", err)
+		return def
+	}
+	return d
+}
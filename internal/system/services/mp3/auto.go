@@ -0,0 +1,98 @@
+package mp3
+
+import "fmt"
+
+// Automatic settings pick the output parameters from the downloaded
+// source stream, so the output never gets a higher bitrate, sample rate or
+// more channels than the audio it is made from. The values chosen are in
+// Report.Options.
+const (
+	// AutoBitrate as Options.Bitrate uses the source bitrate.
+	AutoBitrate = "auto"
+	// Auto as Options.SampleRate or Options.Channels uses the source value.
+	Auto = -1
+)
+
+// mp3Bitrates are the bitrates an MP3 frame can declare, in kbps, by MPEG
+// version.
+var (
+	mp3Bitrates  = []int{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	mp3Bitrates2 = []int{8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+)
+
+// resolveAuto replaces the automatic settings in opts with values derived
+// from the source stream, capped to what the output format supports.
+// Settings the source does not report fall back to the defaults.
+func resolveAuto(opts Options, source AudioFormat) Options {
+	limits := formatLimits[opts.Format]
+	defaults := normalizeOptions(&Options{Format: opts.Format})
+
+	// The sample rate goes first, MP3 bitrates depend on it
+	if opts.SampleRate == Auto {
+		opts.SampleRate = defaults.SampleRate
+		if source.SampleRate > 0 {
+			opts.SampleRate = capSampleRate(limits, source.SampleRate)
+		}
+	}
+
+	if opts.Channels == Auto {
+		opts.Channels = defaults.Channels
+		if source.Channels > 0 {
+			opts.Channels = min(source.Channels, limits.maxChannels)
+		}
+	}
+
+	if opts.Bitrate == AutoBitrate {
+		opts.Bitrate = defaults.Bitrate
+		// Lossless formats have no bitrate to cap
+		if source.Bitrate > 0 && !opts.Format.Lossless() {
+			opts.Bitrate = fmt.Sprintf("%dk", capBitrate(opts, limits, source.Bitrate)/1000)
+		}
+	}
+
+	return opts
+}
+
+// capSampleRate returns the highest supported rate that is not above the
+// source rate, or the lowest supported rate for very low sources.
+func capSampleRate(limits encoderLimits, source int) int {
+	if len(limits.sampleRates) == 0 {
+		return min(max(source, limits.minSampleRate), limits.maxSampleRate)
+	}
+
+	rate := limits.sampleRates[0]
+	for _, r := range limits.sampleRates {
+		if r <= source {
+			rate = r
+		}
+	}
+	return rate
+}
+
+// capBitrate returns the bitrate in bits per second closest to the source
+// bitrate without exceeding it, within the encoder limits. MP3 snaps down
+// to the bitrates its frames can declare.
+func capBitrate(opts Options, limits encoderLimits, source int) int {
+	minRate, maxRate := limits.minBitrate, limits.maxBitrate
+	if opts.Format == FormatMP3 {
+		minRate, maxRate = mp3BitrateLimits(opts.SampleRate)
+	}
+	bitrate := min(max(source, minRate), maxRate)
+
+	if opts.Format != FormatMP3 {
+		return bitrate
+	}
+
+	allowed := mp3Bitrates
+	if opts.SampleRate < 32000 {
+		allowed = mp3Bitrates2
+	}
+
+	kbps := allowed[0]
+	for _, b := range allowed {
+		if b*1000 <= bitrate {
+			kbps = b
+		}
+	}
+	return kbps * 1000
+}
@@ -0,0 +1,69 @@
+package mp3
+
+import (
+	"testing"
+)
+
+func TestResolveAuto(t *testing.T) {
+	auto := Options{Bitrate: AutoBitrate, SampleRate: Auto, Channels: Auto}
+	opus := AudioFormat{Codec: "opus", Bitrate: 135000, SampleRate: 48000, Channels: 2}
+
+	tests := []struct {
+		name   string
+		format Format
+		source AudioFormat
+		want   Options
+	}{
+		// 135k snaps down to the next MP3 frame bitrate
+		{"mp3", FormatMP3, opus, Options{Bitrate: "128k", SampleRate: 48000, Channels: 2}},
+		{"opus", FormatOpus, opus, Options{Bitrate: "135k", SampleRate: 48000, Channels: 2}},
+		{"low source", FormatMP3, AudioFormat{Bitrate: 48000, SampleRate: 22050, Channels: 1}, Options{Bitrate: "48k", SampleRate: 22050, Channels: 1}},
+		// 44.1 kHz is not an Opus rate, the next lower one is used
+		{"opus from aac", FormatOpus, AudioFormat{Bitrate: 129000, SampleRate: 44100, Channels: 2}, Options{Bitrate: "129k", SampleRate: 24000, Channels: 2}},
+		{"unknown source", FormatMP3, AudioFormat{}, Options{Bitrate: "64k", SampleRate: 22050, Channels: 1}},
+		{"flac", FormatFLAC, opus, Options{Bitrate: "64k", SampleRate: 48000, Channels: 2}},
+	}
+
+	for _, tt := range tests {
+		opts := auto
+		opts.Format = tt.format
+		got := resolveAuto(normalizeOptions(&opts), tt.source)
+		if got.Bitrate != tt.want.Bitrate || got.SampleRate != tt.want.SampleRate || got.Channels != tt.want.Channels {
+			t.Errorf("%s: resolved %s at %d Hz, %d channels, want %s at %d Hz, %d channels",
+				tt.name, got.Bitrate, got.SampleRate, got.Channels, tt.want.Bitrate, tt.want.SampleRate, tt.want.Channels)
+		}
+	}
+}
+
+func TestResolveAutoKeepsExplicitSettings(t *testing.T) {
+	opts := normalizeOptions(&Options{Bitrate: "96k", SampleRate: 44100, Channels: 1})
+	got := resolveAuto(opts, AudioFormat{Bitrate: 160000, SampleRate: 48000, Channels: 2})
+	if got.Bitrate != "96k" || got.SampleRate != 44100 || got.Channels != 1 {
+		t.Errorf("resolved %s at %d Hz, %d channels, want the explicit settings", got.Bitrate, got.SampleRate, got.Channels)
+	}
+}
+
+func TestCapBitrate(t *testing.T) {
+	tests := []struct {
+		format     Format
+		sampleRate int
+		source     int
+		want       int
+	}{
+		{FormatMP3, 44100, 500000, 320000},
+		{FormatMP3, 44100, 150000, 128000},
+		{FormatMP3, 44100, 10000, 32000},
+		// MPEG-2 frames at 22050 Hz declare up to 160k
+		{FormatMP3, 22050, 135000, 128000},
+		{FormatMP3, 22050, 320000, 160000},
+		{FormatOpus, 48000, 600000, 510000},
+		{FormatM4A, 44100, 129000, 129000},
+	}
+
+	for _, tt := range tests {
+		opts := Options{Format: tt.format, SampleRate: tt.sampleRate}
+		if got := capBitrate(opts, formatLimits[tt.format], tt.source); got != tt.want {
+			t.Errorf("%s at %d Hz: capBitrate(%d) = %d, want %d", tt.format, tt.sampleRate, tt.source, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package mp3
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockPattern matches positions such as 1:30, 01:02:03 or 1:30.5.
+var clockPattern = regexp.MustCompile(`^(?:(\d+):)?(\d+):(\d{1,2}(?:\.\d+)?)$`)
+
+// ParseTime parses a position in a video for Options.Start and End:
+// a clock such as "1:30" or "01:02:03.5", plain seconds such as "90" or
+// a duration such as "90s" or "1m30s", as in YouTube's t= parameter.
+func ParseTime(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	m := clockPattern.FindStringSubmatch(s)
+	if m == nil {
+		return parseTimestamp(s)
+	}
+
+	// Atoi returns 0 for the missing hours
+	hours, _ := strconv.Atoi(m[1])
+	minutes, _ := strconv.Atoi(m[2])
+	seconds, _ := strconv.ParseFloat(m[3], 64)
+	if seconds >= 60 || (m[1] != "" && minutes >= 60) {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second)).Round(time.Millisecond), nil
+}
+
+// withURLStart makes the t= timestamp of the video URL the start of the
+// output when opts does not set one, see Options.StartSet.
+func withURLStart(opts Options, ref VideoRef) Options {
+	if opts.Start == 0 && !opts.StartSet {
+		opts.Start = ref.Start
+	}
+	return opts
+}
+
+// shiftClip moves Start and End in opts to a stream that begins at offset
+// in the video instead of at its start, see Stream.Start.
+func shiftClip(opts Options, offset time.Duration) Options {
+	if offset == 0 {
+		return opts
+	}
+
+	opts.Start = max(opts.Start-offset, 0)
+	if opts.End > 0 {
+		opts.End -= offset
+	}
+	return opts
+}
@@ -0,0 +1,133 @@
+package mp3
+
+import (
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"1:30", 90 * time.Second},
+		{"01:30", 90 * time.Second},
+		{"01:02:03", time.Hour + 2*time.Minute + 3*time.Second},
+		{"1:30.5", 90*time.Second + 500*time.Millisecond},
+		{"90", 90 * time.Second},
+		{"90s", 90 * time.Second},
+		{"1m30s", 90 * time.Second},
+		{" 2:00 ", 2 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in)
+		if err != nil {
+			t.Errorf("ParseTime(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTime(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimeErrors(t *testing.T) {
+	for _, in := range []string{"", "soon", "1:60", "1:60:00", "-5s", "1:2:3:4"} {
+		if got, err := ParseTime(in); err == nil {
+			t.Errorf("ParseTime(%q) = %s, want an error", in, got)
+		}
+	}
+}
+
+func TestWithURLStart(t *testing.T) {
+	ref := VideoRef{ID: "dQw4w9WgXcQ", Start: 95 * time.Second}
+
+	tests := []struct {
+		name string
+		opts Options
+		want time.Duration
+	}{
+		{"unset", Options{}, 95 * time.Second},
+		{"explicit start", Options{Start: 10 * time.Second}, 10 * time.Second},
+		{"explicit zero", Options{StartSet: true}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := withURLStart(tt.opts, ref).Start; got != tt.want {
+			t.Errorf("%s: Start = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShiftClip(t *testing.T) {
+	opts := shiftClip(Options{Start: 90 * time.Second, End: 150 * time.Second}, 85*time.Second)
+	if opts.Start != 5*time.Second || opts.End != 65*time.Second {
+		t.Errorf("shiftClip = %s-%s, want 5s-1m5s", opts.Start, opts.End)
+	}
+
+	opts = shiftClip(Options{Start: 90 * time.Second}, 95*time.Second)
+	if opts.Start != 0 || opts.End != 0 {
+		t.Errorf("shiftClip past the start = %s-%s, want 0s-0s", opts.Start, opts.End)
+	}
+}
+
+func TestClipArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"none", Options{}, nil},
+		{"start", Options{Start: 90 * time.Second}, []string{"-ss", "90.000"}},
+		{"end", Options{End: 30 * time.Second}, []string{"-t", "30.000"}},
+		{"both", Options{Start: 90 * time.Second, End: 150500 * time.Millisecond}, []string{"-ss", "90.000", "-t", "60.500"}},
+	}
+
+	for _, tt := range tests {
+		if got := clipArgs(tt.opts); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: clipArgs = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSectionArgs(t *testing.T) {
+	got := sectionArgs(Options{Start: 90 * time.Second}, AudioFormat{Container: "webm"})
+	if want := []string{"--download-sections", "*90.000-inf"}; !slices.Equal(got, want) {
+		t.Errorf("webm sectionArgs = %v, want %v", got, want)
+	}
+
+	// MP4 sections are written to a pipe, they must be fragmented
+	got = sectionArgs(Options{Start: 90 * time.Second, End: 2 * time.Minute}, AudioFormat{Container: "m4a"})
+	want := []string{"--download-sections", "*90.000-120.000", "--downloader-args", "ffmpeg_o:-movflags frag_keyframe+empty_moov"}
+	if !slices.Equal(got, want) {
+		t.Errorf("m4a sectionArgs = %v, want %v", got, want)
+	}
+}
+
+func TestConvertToWriterURLStart(t *testing.T) {
+	svc := New(WithExtractors(&fakeExtractor{name: "fake", audio: "audio"}), WithTranscoder(&fakeTranscoder{}))
+	videoURL := testVideoURL + "&t=95"
+
+	tests := []struct {
+		name string
+		opts *Options
+		want time.Duration
+	}{
+		{"from the URL", nil, 95 * time.Second},
+		{"explicit start", &Options{Start: 10 * time.Second, StartSet: true}, 10 * time.Second},
+		{"explicit zero", &Options{StartSet: true}, 0},
+	}
+
+	for _, tt := range tests {
+		report, err := svc.ConvertToWriter(t.Context(), videoURL, io.Discard, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: ConvertToWriter error: %v", tt.name, err)
+		}
+		if report.Options.Start != tt.want {
+			t.Errorf("%s: Start = %s, want %s", tt.name, report.Options.Start, tt.want)
+		}
+	}
+}
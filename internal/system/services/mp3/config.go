@@ -0,0 +1,96 @@
+package mp3
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Option configures a Service created with New.
+type Option func(*Service)
+
+// Timeouts limits how long each stage of a conversion may take.
+// A zero value means no limit.
+type Timeouts struct {
+	// Info limits each metadata lookup.
+	Info time.Duration
+	// Download limits fetching the source audio from one extractor.
+	Download time.Duration
+	// Encode limits transcoding the audio. With streaming extractors such
+	// as yt-dlp the download and the encoding run at the same time.
+	Encode time.Duration
+}
+
+// WithExtractors replaces the default extractors (yt-dlp, then kkdai/youtube).
+// Extractors are tried in the given order until one of them succeeds.
+// Without arguments extraction is disabled, and conversions and video
+// lookups fail with ErrNoExtractors.
+func WithExtractors(extractors ...Extractor) Option {
+	return func(s *Service) {
+		// Not nil, so New keeps the empty list instead of the defaults
+		s.extractors = append([]Extractor{}, extractors...)
+	}
+}
+
+// WithTranscoder replaces the default FFmpeg transcoder.
+func WithTranscoder(transcoder Transcoder) Option {
+	return func(s *Service) {
+		s.transcoder = transcoder
+	}
+}
+
+// WithYTDLPPath sets the yt-dlp executable used by the default extractor.
+func WithYTDLPPath(path string) Option {
+	return func(s *Service) {
+		s.ytdlpPath = path
+	}
+}
+
+// WithFFmpegPath sets the ffmpeg executable used by the default transcoder.
+func WithFFmpegPath(path string) Option {
+	return func(s *Service) {
+		s.ffmpegPath = path
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests made by the
+// service itself, such as the kkdai/youtube extractor. Use it to set a
+// custom transport or proxy (default, also when client is nil: a client
+// with a 30 second timeout).
+// A client without a Transport gets one that refuses internal addresses
+// unless the URLPolicy allows private networks.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Service) {
+		s.httpClient = client
+	}
+}
+
+// WithURLPolicy sets the allowed schemes and hosts for video URLs and
+// whether internal addresses may be reached (default: DefaultURLPolicy).
+func WithURLPolicy(policy URLPolicy) Option {
+	return func(s *Service) {
+		s.policy = policy
+	}
+}
+
+// WithTempDir sets the directory for temporary downloads (default: os.TempDir).
+func WithTempDir(dir string) Option {
+	return func(s *Service) {
+		s.tempDir = dir
+	}
+}
+
+// WithLogger sets the logger for fallbacks and backend failures
+// (default, also when logger is nil: discard all logs).
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Service) {
+		s.logger = logger
+	}
+}
+
+// WithTimeouts sets per-stage timeouts.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(s *Service) {
+		s.timeouts = timeouts
+	}
+}
@@ -0,0 +1,16 @@
+package mp3
+
+import (
+	"testing"
+)
+
+func TestNewNilOptions(t *testing.T) {
+	s := New(WithHTTPClient(nil), WithLogger(nil))
+
+	if s.httpClient == nil || s.httpClient.Timeout == 0 {
+		t.Errorf("httpClient = %+v, want the default client", s.httpClient)
+	}
+	if s.logger == nil {
+		t.Error("logger is nil, want the discarding default")
+	}
+}
@@ -0,0 +1,227 @@
+package mp3
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// coverStyle is how a container embeds cover art.
+type coverStyle int
+
+const (
+	coverNone coverStyle = iota
+	// coverAttached is a picture stream, written by ffmpeg as an ID3 APIC
+	// frame, an MP4 covr atom or a FLAC PICTURE block.
+	coverAttached
+	// coverVorbis is a METADATA_BLOCK_PICTURE comment, which ffmpeg's Ogg
+	// muxer cannot build from a picture stream.
+	coverVorbis
+)
+
+const (
+	// maxCoverSize limits thumbnail downloads.
+	maxCoverSize = 10 << 20
+	// maxCoverAttempts limits how many thumbnails are tried, YouTube
+	// lists sizes that do not exist for every video.
+	maxCoverAttempts = 3
+)
+
+// CoverThumbnail returns the thumbnail embedded as cover art by
+// Options.Cover: the largest JPEG or PNG thumbnail, or false when there
+// is none.
+func (v *VideoInfo) CoverThumbnail() (Thumbnail, bool) {
+	thumbs := v.coverThumbnails()
+	if len(thumbs) == 0 {
+		return Thumbnail{}, false
+	}
+	return thumbs[0], true
+}
+
+// coverThumbnails returns the JPEG and PNG thumbnails from the largest to
+// the smallest, falling back to the thumbnail YouTube has for every video.
+func (v *VideoInfo) coverThumbnails() []Thumbnail {
+	var thumbs []Thumbnail
+	for _, t := range v.Thumbnails {
+		u, err := url.Parse(t.URL)
+		if err != nil {
+			continue
+		}
+		// WebP thumbnails cannot be decoded or embedded everywhere
+		switch strings.ToLower(path.Ext(u.Path)) {
+		case ".jpg", ".jpeg", ".png":
+			thumbs = append(thumbs, t)
+		}
+	}
+
+	slices.SortStableFunc(thumbs, func(a, b Thumbnail) int {
+		return cmp.Compare(b.Width*b.Height, a.Width*a.Height)
+	})
+
+	if len(thumbs) == 0 && v.VideoID != "" {
+		thumbs = append(thumbs, Thumbnail{URL: "https://i.ytimg.com/vi/" + v.VideoID + "/hqdefault.jpg", Width: 480, Height: 360})
+	}
+	return thumbs
+}
+
+// withCover fills opts.Metadata.Cover from the video thumbnail when
+// opts.Cover is set and crops the cover when opts.SquareCover is set.
+// Cover art is optional, failures are logged and the file is written
+// without it, as is a cover larger than the room fitToSize kept for it.
+func (s *Service) withCover(ctx context.Context, opts Options, info *VideoInfo) Options {
+	reserved := coverReserve(opts)
+
+	if opts.Cover && len(opts.Metadata.Cover) == 0 && info != nil {
+		cover, err := s.downloadCover(ctx, info)
+		if err != nil {
+			s.logger.Warn("cover art download failed", "video", info.VideoID, "error", err)
+			return opts
+		}
+		opts.Metadata.Cover = cover
+	}
+
+	if opts.SquareCover && len(opts.Metadata.Cover) > 0 {
+		if square, err := cropSquare(opts.Metadata.Cover); err != nil {
+			s.logger.Warn("cover art crop failed", "error", err)
+		} else {
+			opts.Metadata.Cover = square
+		}
+	}
+
+	if opts.MaxSizeBytes > 0 && int64(len(opts.Metadata.Cover)) > reserved {
+		// The bitrate was picked with less room for the cover
+		s.logger.Warn("cover art left out, it does not fit the size limit", "bytes", len(opts.Metadata.Cover))
+		opts.Metadata.Cover = nil
+	}
+
+	return opts
+}
+
+// downloadCover downloads the first cover thumbnail that exists and is a
+// JPEG or PNG image.
+func (s *Service) downloadCover(ctx context.Context, info *VideoInfo) ([]byte, error) {
+	thumbs := info.coverThumbnails()
+	if len(thumbs) == 0 {
+		return nil, errors.New("video has no thumbnail")
+	}
+
+	var errs []error
+	for _, thumb := range thumbs[:min(len(thumbs), maxCoverAttempts)] {
+		data, err := s.downloadImage(ctx, thumb.URL)
+		if err == nil {
+			return data, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (s *Service) downloadImage(ctx context.Context, imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", imageURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCoverSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", imageURL, err)
+	}
+	if len(data) > maxCoverSize {
+		return nil, fmt.Errorf("%s: image is larger than %s", imageURL, formatSize(maxCoverSize))
+	}
+
+	if _, err := coverConfig(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", imageURL, err)
+	}
+	return data, nil
+}
+
+// coverImage is the decoded header of a cover image.
+type coverImage struct {
+	image.Config
+	mimeType string
+}
+
+// coverConfig decodes the header of a JPEG or PNG image.
+func coverConfig(data []byte) (coverImage, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return coverImage{}, errors.New("cover art must be a JPEG or PNG image")
+	}
+	return coverImage{Config: config, mimeType: "image/" + format}, nil
+}
+
+// cropSquare cuts the largest centered square out of a JPEG or PNG image
+// and encodes it as JPEG.
+func cropSquare(data []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cover art: %w", err)
+	}
+
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	corner := bounds.Min.Add(image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2))
+	square := image.Rectangle{Min: corner, Max: corner.Add(image.Pt(side, side))}
+
+	cropped, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, errors.New("cover art image cannot be cropped")
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, cropped.SubImage(square), &jpeg.Options{Quality: 90}); err != nil {
+		return nil, fmt.Errorf("failed to encode cover art: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// vorbisPicture returns the base64 FLAC picture block Ogg files carry in
+// their METADATA_BLOCK_PICTURE comment.
+func vorbisPicture(data []byte) (string, error) {
+	config, err := coverConfig(data)
+	if err != nil {
+		return "", err
+	}
+
+	var block bytes.Buffer
+	write := func(v uint32) { binary.Write(&block, binary.BigEndian, v) }
+
+	write(3) // front cover
+	write(uint32(len(config.mimeType)))
+	block.WriteString(config.mimeType)
+	write(0) // no description
+	write(uint32(config.Width))
+	write(uint32(config.Height))
+	write(24) // color depth
+	write(0)  // not indexed
+	write(uint32(len(data)))
+	block.Write(data)
+
+	return base64.StdEncoding.EncodeToString(block.Bytes()), nil
+}
@@ -0,0 +1,80 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// testPNG returns a width×height PNG image.
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCoverThumbnails(t *testing.T) {
+	info := &VideoInfo{VideoID: "dQw4w9WgXcQ", Thumbnails: []Thumbnail{
+		{URL: "https://i.ytimg.com/vi/dQw4w9WgXcQ/default.jpg", Width: 120, Height: 90},
+		{URL: "https://i.ytimg.com/vi_webp/dQw4w9WgXcQ/maxresdefault.webp", Width: 1280, Height: 720},
+		{URL: "https://i.ytimg.com/vi/dQw4w9WgXcQ/hqdefault.jpg?sqp=abc", Width: 480, Height: 360},
+	}}
+
+	thumbs := info.coverThumbnails()
+	if len(thumbs) != 2 || thumbs[0].Width != 480 || thumbs[1].Width != 120 {
+		t.Errorf("coverThumbnails = %+v, want the JPEGs from the largest", thumbs)
+	}
+
+	if thumbs := (&VideoInfo{VideoID: "dQw4w9WgXcQ"}).coverThumbnails(); len(thumbs) != 1 || thumbs[0].Width != 480 {
+		t.Errorf("coverThumbnails without thumbnails = %+v, want the hqdefault fallback", thumbs)
+	}
+}
+
+func TestCropSquare(t *testing.T) {
+	square, err := cropSquare(testPNG(t, 480, 360))
+	if err != nil {
+		t.Fatalf("cropSquare error: %v", err)
+	}
+
+	config, err := coverConfig(square)
+	if err != nil {
+		t.Fatalf("coverConfig error: %v", err)
+	}
+	if config.Width != 360 || config.Height != 360 || config.mimeType != "image/jpeg" {
+		t.Errorf("cropped cover = %dx%d %s, want 360x360 image/jpeg", config.Width, config.Height, config.mimeType)
+	}
+
+	if _, err := cropSquare([]byte("not an image")); err == nil {
+		t.Error("cropSquare accepted data that is not an image")
+	}
+}
+
+func TestVorbisPicture(t *testing.T) {
+	data := testPNG(t, 4, 3)
+
+	encoded, err := vorbisPicture(data)
+	if err != nil {
+		t.Fatalf("vorbisPicture error: %v", err)
+	}
+	block, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("picture block is not base64: %v", err)
+	}
+
+	field := func(offset int) uint32 { return binary.BigEndian.Uint32(block[offset:]) }
+	mimeLen := int(field(4))
+	if field(0) != 3 || string(block[8:8+mimeLen]) != "image/png" {
+		t.Errorf("picture type %d, MIME type %q, want 3 and image/png", field(0), block[8:8+mimeLen])
+	}
+	rest := 8 + mimeLen + 4
+	if field(rest) != 4 || field(rest+4) != 3 || !bytes.Equal(block[rest+20:], data) {
+		t.Errorf("picture block does not hold the 4x3 image")
+	}
+}
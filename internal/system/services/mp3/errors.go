@@ -0,0 +1,163 @@
+package mp3
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// Errors reported by the Service. They are wrapped together with the
+// backend specific cause, so use errors.Is to check for them.
+var (
+	// ErrInvalidURL means the input is not a link to a single YouTube video.
+	ErrInvalidURL = errors.New("invalid YouTube URL")
+	// ErrURLNotAllowed means a URL was rejected by the service's URLPolicy.
+	ErrURLNotAllowed = errors.New("URL not allowed")
+	// ErrUnsupportedFormat means the requested output format is not supported.
+	ErrUnsupportedFormat = errors.New("unsupported output format")
+	// ErrInvalidOptions means the Options are not accepted by the encoder of
+	// the output format. The error is ValidationErrors with the details.
+	ErrInvalidOptions = errors.New("invalid options")
+	// ErrUnknownPreset means no preset with the requested name exists.
+	ErrUnknownPreset = errors.New("unknown preset")
+	// ErrTooLarge means the output cannot fit in Options.MaxSizeBytes.
+	ErrTooLarge = errors.New("output would exceed the size limit")
+	// ErrNoChapters means a video cannot be split by chapter because it
+	// has none.
+	ErrNoChapters = errors.New("video has no chapters")
+	// ErrVideoUnavailable means the video does not exist, was removed or is private.
+	ErrVideoUnavailable = errors.New("video unavailable")
+	// ErrAgeRestricted means YouTube requires signing in to confirm the viewer's age.
+	ErrAgeRestricted = errors.New("video is age restricted")
+	// ErrRegionBlocked means the video is not available in the server's country.
+	ErrRegionBlocked = errors.New("video is blocked in this region")
+	// ErrNoExtractors means the Service was created with WithExtractors()
+	// and no extractors, which disables downloads and video lookups.
+	ErrNoExtractors = errors.New("no extractors configured")
+	// ErrBackendMissing means a required tool such as yt-dlp or ffmpeg is not installed.
+	ErrBackendMissing = errors.New("backend not installed")
+	// ErrFFmpegFailed means ffmpeg could not encode the downloaded audio.
+	ErrFFmpegFailed = errors.New("ffmpeg conversion failed")
+	// ErrThrottled means YouTube rejected the download, usually with 403 or 429.
+	ErrThrottled = errors.New("download throttled or forbidden by YouTube")
+	// ErrPartialOutput means a backend failed after part of the output was
+	// already written, so no fallback was attempted.
+	ErrPartialOutput = errors.New("conversion failed after output was written")
+)
+
+// BackendError is the failure of a single extractor.
+type BackendError struct {
+	// Backend is the name of the extractor that failed.
+	Backend string
+	Err     error
+}
+
+func (e *BackendError) Error() string {
+	return e.Backend + ": " + e.Err.Error()
+}
+
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// BackendErrors keeps the failure of every extractor that was tried, in order.
+// errors.Is and errors.As match against any of them.
+type BackendErrors []*BackendError
+
+func (e BackendErrors) Error() string {
+	if len(e) == 0 {
+		return "no extractors configured"
+	}
+
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e BackendErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// FFmpegError is returned when ffmpeg exits with an error.
+// It matches ErrFFmpegFailed with errors.Is.
+type FFmpegError struct {
+	// Stderr is the error output printed by ffmpeg.
+	Stderr string
+	Err    error
+}
+
+func (e *FFmpegError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("ffmpeg conversion failed: %s", e.Stderr)
+	}
+	return fmt.Sprintf("ffmpeg conversion failed: %v", e.Err)
+}
+
+func (e *FFmpegError) Unwrap() error {
+	return e.Err
+}
+
+func (e *FFmpegError) Is(target error) bool {
+	return target == ErrFFmpegFailed
+}
+
+// ytdlpMessages maps yt-dlp error output to the matching error kind.
+var ytdlpMessages = []struct {
+	substr string
+	kind   error
+}{
+	{"confirm your age", ErrAgeRestricted},
+	{"age-restricted", ErrAgeRestricted},
+	{"in your country", ErrRegionBlocked},
+	{"geo restriction", ErrRegionBlocked},
+	{"HTTP Error 403", ErrThrottled},
+	{"HTTP Error 429", ErrThrottled},
+	{"not a bot", ErrThrottled},
+	{"Video unavailable", ErrVideoUnavailable},
+	{"Private video", ErrVideoUnavailable},
+	{"has been removed", ErrVideoUnavailable},
+}
+
+// ytdlpError builds the error for a failed yt-dlp run from its error output.
+func ytdlpError(err error, stderr string) error {
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return fmt.Errorf("yt-dlp failed: %w", err)
+	}
+
+	for _, m := range ytdlpMessages {
+		if strings.Contains(stderr, m.substr) {
+			return fmt.Errorf("%w: %s", m.kind, stderr)
+		}
+	}
+	return fmt.Errorf("yt-dlp failed: %s", stderr)
+}
+
+// libraryError classifies errors returned by the kkdai/youtube client.
+func libraryError(err error) error {
+	var status *youtube.ErrPlayabiltyStatus
+	var code youtube.ErrUnexpectedStatusCode
+
+	switch {
+	case errors.Is(err, youtube.ErrLoginRequired):
+		return fmt.Errorf("%w: %w", ErrAgeRestricted, err)
+	case errors.Is(err, youtube.ErrVideoPrivate):
+		return fmt.Errorf("%w: %w", ErrVideoUnavailable, err)
+	case errors.As(err, &status):
+		if strings.Contains(status.Reason, "country") {
+			return fmt.Errorf("%w: %w", ErrRegionBlocked, err)
+		}
+		return fmt.Errorf("%w: %w", ErrVideoUnavailable, err)
+	case errors.As(err, &code) && (code == 403 || code == 429):
+		return fmt.Errorf("%w: %w", ErrThrottled, err)
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package mp3
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestYTDLPError(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   error
+	}{
+		{"ERROR: [youtube] x: Sign in to confirm your age", ErrAgeRestricted},
+		{"ERROR: unable to download video data: HTTP Error 403: Forbidden", ErrThrottled},
+		{"ERROR: unable to download video data: HTTP Error 429: Too Many Requests", ErrThrottled},
+		{"ERROR: [youtube] x: Video unavailable", ErrVideoUnavailable},
+		{"ERROR: The uploader has not made this video available in your country", ErrRegionBlocked},
+	}
+
+	for _, tt := range tests {
+		if err := ytdlpError(errors.New("exit status 1"), tt.stderr); !errors.Is(err, tt.want) {
+			t.Errorf("ytdlpError(%q) = %v, want %v", tt.stderr, err, tt.want)
+		}
+	}
+
+	// Other failures keep their message and are not classified
+	err := ytdlpError(errors.New("exit status 1"), "ERROR: unable to download video data: connection reset")
+	for _, kind := range []error{ErrThrottled, ErrVideoUnavailable, ErrAgeRestricted, ErrRegionBlocked} {
+		if errors.Is(err, kind) {
+			t.Errorf("unknown failure classified as %v: %v", kind, err)
+		}
+	}
+}
+
+func TestBackendErrors(t *testing.T) {
+	errs := BackendErrors{
+		{Backend: "yt-dlp", Err: ErrThrottled},
+		{Backend: "library", Err: &FFmpegError{Stderr: "Invalid data"}},
+	}
+
+	if got, want := errs.Error(), "yt-dlp: "+ErrThrottled.Error()+"; library: ffmpeg conversion failed: Invalid data"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(errs, ErrThrottled) || !errors.Is(errs, ErrFFmpegFailed) {
+		t.Error("BackendErrors does not match the errors of its backends")
+	}
+
+	var backend *BackendError
+	if !errors.As(errs, &backend) || backend.Backend != "yt-dlp" {
+		t.Errorf("errors.As found %+v, want the first backend", backend)
+	}
+}
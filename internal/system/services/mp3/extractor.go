@@ -0,0 +1,48 @@
+package mp3
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Extractor resolves YouTube videos and opens their audio streams.
+// The Service tries its extractors in order until one of them succeeds,
+// so additional sources (mirrors, caches, in-memory fakes) can be plugged
+// in with WithExtractors.
+type Extractor interface {
+	// Name identifies the extractor in logs and error messages.
+	Name() string
+	// Info retrieves metadata about the video without downloading it.
+	Info(ctx context.Context, videoURL string) (*VideoInfo, error)
+	// Open starts downloading the best audio stream for the video.
+	// Extractors report download progress to opts.Progress when it is set,
+	// and prefer a stream that fits opts.Format when opts.Copy is set.
+	// opts.Info, when set, describes the video so it need not be looked
+	// up again.
+	// They may download only the part between opts.Start and opts.End,
+	// see Stream.Start.
+	// The caller must close the returned stream.
+	Open(ctx context.Context, videoURL string, opts Options) (*Stream, error)
+}
+
+// Stream is an audio stream opened by an Extractor.
+type Stream struct {
+	io.ReadCloser
+	// Size is the length of the stream in bytes, or 0 when unknown.
+	Size int64
+	// Duration is the length of the audio, or 0 when unknown. For a stream
+	// that covers part of the video it is still the length of the video.
+	Duration time.Duration
+	// Start is the position in the video the stream begins at, 0 unless
+	// the extractor skipped the audio before Options.Start. The stream may
+	// also end anywhere after Options.End.
+	Start time.Duration
+	// Format describes the source stream that was picked, zero fields are
+	// unknown. Options.Copy needs the codec, automatic output settings
+	// need the bitrate, sample rate and channels.
+	Format AudioFormat
+	// Info describes the video, or is nil when the extractor did not look
+	// it up. It provides the default tags of the output.
+	Info *VideoInfo
+}
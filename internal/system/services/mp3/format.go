@@ -0,0 +1,163 @@
+package mp3
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Format is an output audio format. Each format fixes the codec, the
+// container, the MIME type and the file extension of the output.
+type Format string
+
+const (
+	// FormatMP3 is MPEG-1 Layer III audio encoded with LAME.
+	FormatMP3 Format = "mp3"
+	// FormatM4A is AAC audio in an MP4 container.
+	FormatM4A Format = "m4a"
+	// FormatOpus is Opus audio in an Ogg container.
+	FormatOpus Format = "opus"
+	// FormatWebM is Opus audio in a WebM container, the container YouTube
+	// serves Opus in.
+	FormatWebM Format = "webm"
+	// FormatFLAC is lossless FLAC audio.
+	FormatFLAC Format = "flac"
+	// FormatWAV is uncompressed 16-bit PCM audio in a WAV container.
+	FormatWAV Format = "wav"
+)
+
+// formatSpec holds the ffmpeg and HTTP details of a Format.
+type formatSpec struct {
+	codec     string
+	muxer     string
+	mimeType  string
+	extension string
+	lossless  bool
+	// sampleRate replaces the default sample rate for codecs that do not
+	// support 22050 Hz.
+	sampleRate int
+	// mode replaces the default bitrate mode for codecs that work best
+	// with another one.
+	mode BitrateMode
+	// copyCodecs lists the source codecs the container can hold as they
+	// are, for Options.Copy.
+	copyCodecs []string
+	// seekableVBR is set for muxers that need to rewrite the start of
+	// variable bitrate output once encoding is done.
+	seekableVBR bool
+	// cover is how the container embeds cover art.
+	cover coverStyle
+	// chapters is set for muxers that write ffmpeg chapters: ID3 CHAP and
+	// CTOC frames, MP4 chapter tracks, Vorbis CHAPTERxxx comments and
+	// Matroska chapters.
+	chapters bool
+}
+
+var formatSpecs = map[Format]formatSpec{
+	FormatMP3: {codec: "libmp3lame", muxer: "mp3", mimeType: "audio/mpeg", extension: ".mp3", seekableVBR: true, cover: coverAttached, chapters: true},
+	FormatM4A: {
+		codec: "aac", muxer: "mp4", mimeType: "audio/mp4", extension: ".m4a",
+		copyCodecs: []string{"aac"}, cover: coverAttached, chapters: true,
+	},
+	FormatOpus: {
+		codec: "libopus", muxer: "ogg", mimeType: "audio/ogg", extension: ".opus",
+		sampleRate: 24000, mode: ModeVBR, copyCodecs: []string{"opus"}, cover: coverVorbis, chapters: true,
+	},
+	FormatWebM: {
+		codec: "libopus", muxer: "webm", mimeType: "audio/webm", extension: ".webm",
+		sampleRate: 24000, mode: ModeVBR, copyCodecs: []string{"opus", "vorbis"}, chapters: true,
+	},
+	FormatFLAC: {codec: "flac", muxer: "flac", mimeType: "audio/flac", extension: ".flac", lossless: true, cover: coverAttached},
+	FormatWAV:  {codec: "pcm_s16le", muxer: "wav", mimeType: "audio/wav", extension: ".wav", lossless: true},
+}
+
+// formatAliases maps alternative names accepted by ParseFormat.
+var formatAliases = map[string]Format{
+	"aac": FormatM4A,
+	"mp4": FormatM4A,
+	"ogg": FormatOpus,
+}
+
+// Formats returns every supported output format.
+func Formats() []Format {
+	return []Format{FormatMP3, FormatM4A, FormatOpus, FormatWebM, FormatFLAC, FormatWAV}
+}
+
+// ParseFormat returns the Format for a name such as "mp3", "aac" or "ogg".
+// Errors wrap ErrUnsupportedFormat.
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if f, ok := formatAliases[name]; ok {
+		return f, nil
+	}
+
+	f := Format(name)
+	if !f.Valid() {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedFormat, name)
+	}
+	return f, nil
+}
+
+// Valid reports whether f is a supported format.
+func (f Format) Valid() bool {
+	_, ok := formatSpecs[f]
+	return ok
+}
+
+// Codec returns the ffmpeg encoder for the format.
+func (f Format) Codec() string {
+	return formatSpecs[f].codec
+}
+
+// MIMEType returns the Content-Type of files in this format.
+func (f Format) MIMEType() string {
+	return formatSpecs[f].mimeType
+}
+
+// Extension returns the file extension including the dot, such as ".mp3".
+func (f Format) Extension() string {
+	return formatSpecs[f].extension
+}
+
+// Lossless reports whether the format ignores the bitrate.
+func (f Format) Lossless() bool {
+	return formatSpecs[f].lossless
+}
+
+// muxer returns the ffmpeg output format for -f.
+func (f Format) muxer() string {
+	return formatSpecs[f].muxer
+}
+
+// seekable reports whether the format must be encoded into a seekable
+// file in the given bitrate mode.
+func (f Format) seekable(mode BitrateMode) bool {
+	return formatSpecs[f].seekableVBR && mode == ModeVBR
+}
+
+// SupportsCover reports whether files in this format can embed cover art.
+func (f Format) SupportsCover() bool {
+	return formatSpecs[f].cover != coverNone
+}
+
+// SupportsChapters reports whether files in this format can hold chapters.
+func (f Format) SupportsChapters() bool {
+	return formatSpecs[f].chapters
+}
+
+// canCopy reports whether audio in the source codec, as returned by
+// normalizeCodec, can be stored in the format without re-encoding.
+func (f Format) canCopy(codec string) bool {
+	return codec != "" && slices.Contains(formatSpecs[f].copyCodecs, codec)
+}
+
+// normalizeCodec turns codec names such as "mp4a.40.2" or `"opus"` into
+// the names used by the formats, such as "aac" or "opus".
+func normalizeCodec(codec string) string {
+	codec = strings.ToLower(strings.Trim(codec, `" `))
+	codec, _, _ = strings.Cut(codec, ".")
+	if codec == "mp4a" {
+		return "aac"
+	}
+	return codec
+}
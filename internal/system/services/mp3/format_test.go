@@ -0,0 +1,85 @@
+package mp3
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Format
+	}{
+		{"mp3", FormatMP3},
+		{" MP3 ", FormatMP3},
+		{"m4a", FormatM4A},
+		{"aac", FormatM4A},
+		{"mp4", FormatM4A},
+		{"opus", FormatOpus},
+		{"ogg", FormatOpus},
+		{"webm", FormatWebM},
+		{"flac", FormatFLAC},
+		{"wav", FormatWAV},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.in)
+		if err != nil {
+			t.Errorf("ParseFormat(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFormat(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "ogv", "mp"} {
+		if _, err := ParseFormat(in); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("ParseFormat(%q) error = %v, want ErrUnsupportedFormat", in, err)
+		}
+	}
+}
+
+func TestFormatSpecs(t *testing.T) {
+	for _, f := range Formats() {
+		if !f.Valid() || f.Codec() == "" || f.MIMEType() == "" || f.Extension() != "."+string(f) {
+			t.Errorf("format %s: codec %q, MIME type %q, extension %q", f, f.Codec(), f.MIMEType(), f.Extension())
+		}
+	}
+}
+
+func TestNormalizeCodec(t *testing.T) {
+	tests := map[string]string{
+		"mp4a.40.2": "aac",
+		`"opus"`:    "opus",
+		"Vorbis":    "vorbis",
+		"":          "",
+	}
+
+	for in, want := range tests {
+		if got := normalizeCodec(in); got != want {
+			t.Errorf("normalizeCodec(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFormatCanCopy(t *testing.T) {
+	tests := []struct {
+		format Format
+		codec  string
+		want   bool
+	}{
+		{FormatM4A, "aac", true},
+		{FormatOpus, "opus", true},
+		{FormatWebM, "vorbis", true},
+		{FormatOpus, "aac", false},
+		{FormatMP3, "opus", false},
+		{FormatM4A, "", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.format.canCopy(tt.codec); got != tt.want {
+			t.Errorf("%s canCopy(%q) = %v, want %v", tt.format, tt.codec, got, tt.want)
+		}
+	}
+}
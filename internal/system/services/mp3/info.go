@@ -0,0 +1,69 @@
+package mp3
+
+import "time"
+
+// VideoInfo contains metadata about a YouTube video.
+type VideoInfo struct {
+	Title       string
+	Author      string
+	Duration    time.Duration
+	VideoID     string
+	ChannelID   string
+	Description string
+	// PublishDate is the day the video was uploaded, zero when unknown.
+	PublishDate time.Time
+	// Views is the view count at the time of the lookup.
+	Views int64
+	// Thumbnails lists the available thumbnails, in no particular order.
+	Thumbnails []Thumbnail
+	// AudioFormats lists the audio-only streams available for download.
+	AudioFormats []AudioFormat
+	// Chapters lists the chapters of the video in order, empty when it
+	// has none.
+	Chapters []Chapter
+}
+
+// Chapter is a titled section of a video.
+type Chapter struct {
+	Title string
+	Start time.Duration
+	// End is where the chapter stops, 0 when it lasts until the next
+	// chapter or the end of the video.
+	End time.Duration
+}
+
+// Thumbnail is a preview image of a video.
+type Thumbnail struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+// AudioFormat describes an audio stream offered by YouTube.
+type AudioFormat struct {
+	// ID identifies the format to the extractor (itag or yt-dlp format id).
+	ID string
+	// Codec is the audio codec, such as "opus" or "mp4a.40.2".
+	Codec string
+	// Container is the file extension of the stream, such as "webm" or "m4a".
+	Container string
+	// Bitrate is the average bitrate in bits per second, 0 when unknown.
+	Bitrate int
+	// Size is the length of the stream in bytes, 0 when unknown.
+	Size       int64
+	SampleRate int
+	Channels   int
+	// Language is the audio track language, empty for the default track.
+	Language string
+}
+
+// BestThumbnail returns the largest thumbnail, or false when there are none.
+func (v *VideoInfo) BestThumbnail() (Thumbnail, bool) {
+	var best Thumbnail
+	for _, t := range v.Thumbnails {
+		if t.Width*t.Height >= best.Width*best.Height {
+			best = t
+		}
+	}
+	return best, best.URL != ""
+}
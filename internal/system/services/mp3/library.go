@@ -0,0 +1,196 @@
+package mp3
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// LibraryExtractor extracts audio using the kkdai/youtube library.
+// It needs no external tools but is more likely to be blocked by YouTube.
+type LibraryExtractor struct {
+	// Client is the YouTube client used for requests. When nil, a client
+	// with a 30 second HTTP timeout is used.
+	Client *youtube.Client
+	// TempDir is the directory for the downloaded audio (default: os.TempDir).
+	TempDir string
+}
+
+// Name returns the extractor name.
+func (e *LibraryExtractor) Name() string {
+	return "kkdai/youtube"
+}
+
+// Info retrieves video metadata from the YouTube player response.
+func (e *LibraryExtractor) Info(ctx context.Context, videoURL string) (*VideoInfo, error) {
+	video, err := e.client().GetVideoContext(ctx, videoURL)
+	if err != nil {
+		return nil, libraryError(err)
+	}
+
+	return libraryVideoInfo(video), nil
+}
+
+// Open downloads the audio format chosen by opts.Source to a temporary
+// file and returns a stream reading from it. The file is removed when the stream is closed.
+func (e *LibraryExtractor) Open(ctx context.Context, videoURL string, opts Options) (*Stream, error) {
+	client := e.client()
+
+	video, err := client.GetVideoContext(ctx, videoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video info: %w", libraryError(err))
+	}
+
+	format := selectAudioFormat(video.Formats, video.Duration, opts)
+	if format == nil {
+		return nil, fmt.Errorf("no audio formats available")
+	}
+
+	// YouTube blocks direct streaming, so we download to a temp file first
+	stream, total, err := client.GetStreamContext(ctx, video, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video stream: %w", libraryError(err))
+	}
+	defer stream.Close()
+
+	var src io.Reader = stream
+	if opts.Progress != nil {
+		src = &progressReader{Reader: stream, fn: opts.Progress, total: total}
+	}
+
+	tempFile, err := os.CreateTemp(e.TempDir, "gomp3-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	size, err := io.Copy(tempFile, src)
+	if err != nil {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+		// Only 403 and 429 responses are YouTube blocking the download
+		return nil, fmt.Errorf("failed to download video: %w", libraryError(err))
+	}
+
+	if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+		return nil, fmt.Errorf("failed to rewind temp file: %w", err)
+	}
+
+	return &Stream{
+		ReadCloser: &tempFileReader{File: tempFile},
+		Size:       size,
+		Duration:   video.Duration,
+		Format:     libraryAudioFormat(*format),
+		Info:       libraryVideoInfo(video),
+	}, nil
+}
+
+func (e *LibraryExtractor) client() *youtube.Client {
+	if e.Client != nil {
+		return e.Client
+	}
+	return newYouTubeClient()
+}
+
+func newYouTubeClient() *youtube.Client {
+	// Create HTTP client with proper timeout to avoid being blocked
+	return &youtube.Client{
+		HTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// selectAudioFormat picks the audio-only format ranked first by the
+// source policy in opts. Videos without audio-only formats fall back to
+// the first format that has audio.
+func selectAudioFormat(formats youtube.FormatList, duration time.Duration, opts Options) *youtube.Format {
+	audio := formats.Type("audio/")
+	if len(audio) == 0 {
+		withAudio := formats.WithAudioChannels()
+		if len(withAudio) == 0 {
+			return nil
+		}
+		return &withAudio[0]
+	}
+
+	candidates := make([]AudioFormat, len(audio))
+	for i, f := range audio {
+		candidates[i] = libraryAudioFormat(f)
+	}
+	return &audio[rankAudioFormats(candidates, duration, opts)[0]]
+}
+
+func libraryVideoInfo(video *youtube.Video) *VideoInfo {
+	info := &VideoInfo{
+		Title:       video.Title,
+		Author:      video.Author,
+		Duration:    video.Duration,
+		VideoID:     video.ID,
+		ChannelID:   video.ChannelID,
+		Description: video.Description,
+		PublishDate: video.PublishDate,
+		Views:       int64(video.Views),
+	}
+
+	for _, t := range video.Thumbnails {
+		info.Thumbnails = append(info.Thumbnails, Thumbnail{URL: t.URL, Width: int(t.Width), Height: int(t.Height)})
+	}
+
+	for _, f := range video.Formats.Type("audio/") {
+		info.AudioFormats = append(info.AudioFormats, libraryAudioFormat(f))
+	}
+
+	return info
+}
+
+// libraryAudioFormat converts a YouTube format with a MIME type such as
+// `audio/webm; codecs="opus"` into an AudioFormat.
+func libraryAudioFormat(f youtube.Format) AudioFormat {
+	mimeType, params, _ := strings.Cut(f.MimeType, ";")
+	container := strings.TrimPrefix(strings.TrimSpace(mimeType), "audio/")
+	if container == "mp4" {
+		container = "m4a"
+	}
+
+	_, codec, _ := strings.Cut(params, "codecs=")
+	sampleRate, _ := strconv.Atoi(f.AudioSampleRate)
+
+	// Track IDs look like "en-US.4", the part before the dot is the language
+	var language string
+	if f.AudioTrack != nil {
+		language, _, _ = strings.Cut(f.AudioTrack.ID, ".")
+		language = cmp.Or(language, f.AudioTrack.DisplayName)
+	}
+
+	return AudioFormat{
+		ID:         strconv.Itoa(f.ItagNo),
+		Codec:      strings.Trim(codec, `" `),
+		Container:  container,
+		Bitrate:    cmp.Or(f.AverageBitrate, f.Bitrate),
+		Size:       f.ContentLength,
+		SampleRate: sampleRate,
+		Channels:   f.AudioChannels,
+		Language:   language,
+	}
+}
+
+// tempFileReader reads a downloaded temporary file and removes it when closed.
+type tempFileReader struct {
+	*os.File
+}
+
+func (r *tempFileReader) Close() error {
+	err := r.File.Close()
+	os.Remove(r.File.Name())
+	return err
+}
@@ -0,0 +1,180 @@
+package mp3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Names of the loudness presets accepted by ParseLoudness.
+const (
+	// LoudnessPodcast is -16 LUFS with peaks below -1.5 dBTP, the level
+	// most podcast platforms recommend.
+	LoudnessPodcast = "podcast"
+	// LoudnessMusic is -14 LUFS with peaks below -1 dBTP, the level music
+	// streaming services play at. The wide range keeps the dynamics.
+	LoudnessMusic = "music"
+)
+
+// Defaults for the Loudness fields left at zero.
+const (
+	defaultTruePeak      = -1.5
+	defaultLoudnessRange = 11
+)
+
+var loudnessPresets = map[string]Loudness{
+	LoudnessPodcast: {Integrated: -16, TruePeak: -1.5, Range: 11},
+	LoudnessMusic:   {Integrated: -14, TruePeak: -1, Range: 20},
+}
+
+// Loudness is an EBU R128 loudness target for Options.Loudness. The zero
+// value leaves the loudness of the source as it is.
+type Loudness struct {
+	// Integrated is the target loudness in LUFS, from -70 to -5.
+	Integrated float64
+	// TruePeak is the highest true peak in dBTP, from -9 to 0 (default:
+	// -1.5)
+	TruePeak float64
+	// Range is the largest loudness range in LU, from 1 to 20 (default:
+	// 11). Sources with a wider range are compressed to fit it.
+	Range float64
+}
+
+// Enabled reports whether l sets a target.
+func (l Loudness) Enabled() bool {
+	return l.Integrated != 0
+}
+
+// ParseLoudness returns the Loudness for a preset name, LoudnessPodcast
+// or LoudnessMusic, or for a target loudness such as "-18" or "-18LUFS".
+func ParseLoudness(s string) (Loudness, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if l, ok := loudnessPresets[name]; ok {
+		return l, nil
+	}
+
+	target, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(name, "lufs")), 64)
+	if err != nil || target >= 0 {
+		return Loudness{}, fmt.Errorf("invalid loudness %q, use podcast, music or a target such as -18", s)
+	}
+	return Loudness{Integrated: target}, nil
+}
+
+// withDefaults fills the true peak and range of an enabled target.
+func (l Loudness) withDefaults() Loudness {
+	if !l.Enabled() {
+		return l
+	}
+	if l.TruePeak == 0 {
+		l.TruePeak = defaultTruePeak
+	}
+	if l.Range == 0 {
+		l.Range = defaultLoudnessRange
+	}
+	return l
+}
+
+// validate returns the fields of l outside of the ranges loudnorm takes.
+func (l Loudness) validate() ValidationErrors {
+	if !l.Enabled() {
+		return nil
+	}
+
+	var errs ValidationErrors
+	if l.Integrated < -70 || l.Integrated > -5 {
+		errs = append(errs, &FieldError{Field: "Loudness.Integrated", Message: fmt.Sprintf("%g LUFS is not between -70 and -5", l.Integrated)})
+	}
+	if l.TruePeak < -9 || l.TruePeak > 0 {
+		errs = append(errs, &FieldError{Field: "Loudness.TruePeak", Message: fmt.Sprintf("%g dBTP is not between -9 and 0", l.TruePeak)})
+	}
+	if l.Range < 1 || l.Range > 20 {
+		errs = append(errs, &FieldError{Field: "Loudness.Range", Message: fmt.Sprintf("%g LU is not between 1 and 20", l.Range)})
+	}
+	return errs
+}
+
+// LoudnessStats is the loudness of the source, measured by the first
+// normalization pass.
+type LoudnessStats struct {
+	// Integrated is the loudness of the whole source in LUFS.
+	Integrated float64
+	// TruePeak is the highest true peak in dBTP.
+	TruePeak float64
+	// Range is the loudness range in LU.
+	Range float64
+	// Threshold is the gate below which audio is left out of the
+	// measurement, in LUFS.
+	Threshold float64
+	// Offset is the gain in LU the second pass adds to reach the target.
+	Offset float64
+}
+
+// loudnormFilter returns the loudnorm filter for target. Without stats it
+// measures the audio, with them it normalizes it linearly when the range
+// of the source fits target.Range and dynamically otherwise.
+func loudnormFilter(target Loudness, stats *LoudnessStats) string {
+	filter := fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%s",
+		loudnormValue(target.Integrated), loudnormValue(target.TruePeak), loudnormValue(target.Range))
+	if stats == nil {
+		return filter + ":print_format=json"
+	}
+
+	return filter + fmt.Sprintf(":measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+		loudnormValue(stats.Integrated), loudnormValue(stats.TruePeak), loudnormValue(stats.Range),
+		loudnormValue(stats.Threshold), loudnormValue(stats.Offset))
+}
+
+func loudnormValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// loudnormJSON is the measurement loudnorm prints with print_format=json.
+// It writes every value as a string.
+type loudnormJSON struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// parseLoudnorm finds the measurement in the log output of the first
+// pass. Silent audio measures -inf, which the second pass does not take,
+// so it is reported with audible set to false.
+func parseLoudnorm(log string) (stats *LoudnessStats, audible bool, err error) {
+	start := strings.LastIndex(log, "{")
+	end := strings.LastIndex(log, "}")
+	if start < 0 || end < start {
+		return nil, false, errors.New("loudnorm printed no measurement")
+	}
+
+	var data loudnormJSON
+	if err := json.Unmarshal([]byte(log[start:end+1]), &data); err != nil {
+		return nil, false, fmt.Errorf("failed to parse the loudnorm measurement: %w", err)
+	}
+
+	stats = &LoudnessStats{}
+	fields := []struct {
+		text string
+		dst  *float64
+	}{
+		{data.InputI, &stats.Integrated},
+		{data.InputTP, &stats.TruePeak},
+		{data.InputLRA, &stats.Range},
+		{data.InputThresh, &stats.Threshold},
+		{data.TargetOffset, &stats.Offset},
+	}
+	audible = true
+	for _, field := range fields {
+		if *field.dst, err = strconv.ParseFloat(strings.TrimSpace(field.text), 64); err != nil {
+			return nil, false, fmt.Errorf("invalid loudnorm value %q", field.text)
+		}
+		if math.IsInf(*field.dst, 0) {
+			audible = false
+		}
+	}
+	return stats, audible, nil
+}
@@ -0,0 +1,85 @@
+package mp3
+
+import (
+	"testing"
+)
+
+func TestParseLoudness(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Loudness
+	}{
+		{"podcast", Loudness{Integrated: -16, TruePeak: -1.5, Range: 11}},
+		{"Music", Loudness{Integrated: -14, TruePeak: -1, Range: 20}},
+		{"-18", Loudness{Integrated: -18}},
+		{"-18LUFS", Loudness{Integrated: -18}},
+		{"-23.5 lufs", Loudness{Integrated: -23.5}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLoudness(tt.in)
+		if err != nil {
+			t.Errorf("ParseLoudness(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLoudness(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "loud", "0", "14"} {
+		if got, err := ParseLoudness(in); err == nil {
+			t.Errorf("ParseLoudness(%q) = %+v, want an error", in, got)
+		}
+	}
+}
+
+func TestParseLoudnorm(t *testing.T) {
+	const log = `[Parsed_loudnorm_0 @ 0x5581]
+{
+	"input_i" : "-27.61",
+	"input_tp" : "-4.47",
+	"input_lra" : "18.06",
+	"input_thresh" : "-39.20",
+	"output_i" : "-16.58",
+	"output_tp" : "-1.50",
+	"output_lra" : "14.78",
+	"output_thresh" : "-27.71",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.58"
+}`
+
+	stats, audible, err := parseLoudnorm("size=N/A time=00:03:12.00\n" + log)
+	if err != nil {
+		t.Fatalf("parseLoudnorm error: %v", err)
+	}
+	want := LoudnessStats{Integrated: -27.61, TruePeak: -4.47, Range: 18.06, Threshold: -39.2, Offset: 0.58}
+	if !audible || *stats != want {
+		t.Errorf("parseLoudnorm = %+v, %v, want %+v, true", *stats, audible, want)
+	}
+
+	silent := `{"input_i" : "-inf", "input_tp" : "-inf", "input_lra" : "0.00", "input_thresh" : "-70.00", "target_offset" : "inf"}`
+	if _, audible, err := parseLoudnorm(silent); err != nil || audible {
+		t.Errorf("parseLoudnorm(silence) = %v, %v, want not audible", audible, err)
+	}
+
+	for _, bad := range []string{"", "no measurement here", `{"input_i" : "loud"}`} {
+		if _, _, err := parseLoudnorm(bad); err == nil {
+			t.Errorf("parseLoudnorm(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestLoudnormFilter(t *testing.T) {
+	target := Loudness{Integrated: -16}.withDefaults()
+
+	if got, want := loudnormFilter(target, nil), "loudnorm=I=-16.00:TP=-1.50:LRA=11.00:print_format=json"; got != want {
+		t.Errorf("measure filter = %q, want %q", got, want)
+	}
+
+	stats := &LoudnessStats{Integrated: -27.61, TruePeak: -4.47, Range: 18.06, Threshold: -39.2, Offset: 0.58}
+	want := "loudnorm=I=-16.00:TP=-1.50:LRA=11.00:measured_I=-27.61:measured_TP=-4.47:measured_LRA=18.06:measured_thresh=-39.20:offset=0.58:linear=true"
+	if got := loudnormFilter(target, stats); got != want {
+		t.Errorf("normalize filter = %q, want %q", got, want)
+	}
+}
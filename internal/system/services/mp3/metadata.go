@@ -0,0 +1,172 @@
+package mp3
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// videoIDTag is the TXXX frame (or tag in other containers) holding the
+// YouTube video ID.
+const videoIDTag = "YOUTUBE_VIDEO_ID"
+
+// datePattern matches the dates ID3v2.4 accepts: YYYY, YYYY-MM or
+// YYYY-MM-DD.
+var datePattern = regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2})?)?$`)
+
+// Metadata are the tags written to the output file, as ID3v2.4 frames for
+// MP3 and as the container's own tags for the other formats. Empty fields
+// are filled from the video.
+type Metadata struct {
+	// Title is the track title (default: the video title)
+	Title string
+	// Artist is the performer (default: the channel name, without the
+	// " - Topic" suffix of YouTube's auto-generated music channels)
+	Artist string
+	// Album is the album name, unset unless given
+	Album string
+	// Date is the release date as YYYY, YYYY-MM or YYYY-MM-DD (default:
+	// the upload date)
+	Date string
+	// Track and TrackTotal are the track number and the number of tracks,
+	// unset unless given or written by SplitChapters
+	Track, TrackTotal int
+	// Comment is a free text comment (default: the video URL)
+	Comment string
+	// VideoID is written to a YOUTUBE_VIDEO_ID TXXX frame (default: the
+	// converted video's ID)
+	VideoID string
+	// Cover is a JPEG or PNG image embedded as the front cover (default:
+	// the video thumbnail when Options.Cover is set). WebM and WAV files
+	// cannot embed cover art.
+	Cover []byte
+	// Chapters are written as ID3 CHAP and CTOC frames in MP3 and as the
+	// container's own chapters in M4A, Opus and WebM (default: the video's
+	// chapters from Options.Chapters). An empty, non-nil slice writes no
+	// chapters.
+	Chapters []Chapter
+}
+
+// withDefaults fills the empty fields of m from the video, taking the
+// chapters from source. info may be nil when the extractor could not
+// describe the video, only the fields that come from the URL are filled
+// then.
+func (m Metadata) withDefaults(info *VideoInfo, videoURL string, source ChapterSource) Metadata {
+	m.Comment = cmp.Or(m.Comment, videoURL)
+	if ref, err := ParseVideoURL(videoURL); err == nil {
+		m.VideoID = cmp.Or(m.VideoID, ref.ID)
+	}
+
+	if info == nil {
+		return m
+	}
+
+	m.Title = cmp.Or(m.Title, info.Title)
+	m.Artist = cmp.Or(m.Artist, strings.TrimSuffix(info.Author, " - Topic"))
+	if m.Date == "" && !info.PublishDate.IsZero() {
+		m.Date = info.PublishDate.Format("2006-01-02")
+	}
+	m.VideoID = cmp.Or(m.VideoID, info.VideoID)
+	if m.Chapters == nil {
+		m.Chapters = info.ChaptersFrom(source)
+	}
+	return m
+}
+
+// closeChapters returns chapters with every End set, to the start of the
+// next chapter or to the end of the audio at total.
+func closeChapters(chapters []Chapter, total time.Duration) []Chapter {
+	if len(chapters) == 0 {
+		return chapters
+	}
+
+	closed := slices.Clone(chapters)
+	for i := range closed {
+		if closed[i].End > 0 {
+			continue
+		}
+		if i+1 < len(closed) {
+			closed[i].End = closed[i+1].Start
+		} else {
+			closed[i].End = total
+		}
+	}
+	return closed
+}
+
+// metadataArgs returns the ffmpeg flags writing m to the output.
+func metadataArgs(m Metadata) []string {
+	var args []string
+
+	tags := []struct{ key, value string }{
+		{"title", m.Title},
+		{"artist", m.Artist},
+		{"album", m.Album},
+		{"date", m.Date},
+		{"comment", m.Comment},
+		{videoIDTag, m.VideoID},
+	}
+	for _, tag := range tags {
+		if tag.value != "" {
+			args = append(args, "-metadata", tag.key+"="+tag.value)
+		}
+	}
+
+	switch {
+	case m.Track > 0 && m.TrackTotal > 0:
+		args = append(args, "-metadata", fmt.Sprintf("track=%d/%d", m.Track, m.TrackTotal))
+	case m.Track > 0:
+		args = append(args, "-metadata", fmt.Sprintf("track=%d", m.Track))
+	}
+	return args
+}
+
+// escapeMetadata escapes the characters FFMETADATA files treat specially.
+func escapeMetadata(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '=', ';', '#', '\\', '\n':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// trimChapters returns the chapters that overlap the part of the audio
+// between start and end, shifted to start at 0. A zero end is the end of
+// the audio.
+func trimChapters(chapters []Chapter, start, end time.Duration) []Chapter {
+	if start == 0 && end == 0 {
+		return chapters
+	}
+
+	trimmed := []Chapter{}
+	for _, c := range chapters {
+		if (end > 0 && c.Start >= end) || (c.End > 0 && c.End <= start) {
+			continue
+		}
+
+		if c.End == 0 || (end > 0 && c.End > end) {
+			c.End = end
+		}
+		c.Start = max(c.Start, start) - start
+		if c.End > 0 {
+			c.End -= start
+		}
+		trimmed = append(trimmed, c)
+	}
+	return trimmed
+}
+
+// writeChapters writes chapters as FFMETADATA [CHAPTER] sections.
+func writeChapters(b *strings.Builder, chapters []Chapter) {
+	for _, c := range chapters {
+		fmt.Fprintf(b, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			c.Start.Milliseconds(), max(c.End, c.Start).Milliseconds(), escapeMetadata(c.Title))
+	}
+}
@@ -0,0 +1,95 @@
+package mp3
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetadataWithDefaults(t *testing.T) {
+	info := &VideoInfo{
+		Title:       "Song (Official Video)",
+		Author:      "Band - Topic",
+		VideoID:     "dQw4w9WgXcQ",
+		PublishDate: time.Date(2009, 10, 25, 0, 0, 0, 0, time.UTC),
+	}
+
+	got := Metadata{Album: "Live"}.withDefaults(info, testVideoURL, ChaptersOfficial)
+	want := Metadata{
+		Title:   "Song (Official Video)",
+		Artist:  "Band",
+		Album:   "Live",
+		Date:    "2009-10-25",
+		Comment: testVideoURL,
+		VideoID: "dQw4w9WgXcQ",
+	}
+	if !equalTags(got, want) {
+		t.Errorf("withDefaults = %+v, want %+v", got, want)
+	}
+
+	// Given tags win over the video, the URL still fills what it can
+	got = Metadata{Title: "Mine", Date: "2020"}.withDefaults(nil, testVideoURL, ChaptersOfficial)
+	want = Metadata{Title: "Mine", Date: "2020", Comment: testVideoURL, VideoID: "dQw4w9WgXcQ"}
+	if !equalTags(got, want) {
+		t.Errorf("withDefaults without info = %+v, want %+v", got, want)
+	}
+}
+
+func equalTags(a, b Metadata) bool {
+	return a.Title == b.Title && a.Artist == b.Artist && a.Album == b.Album && a.Date == b.Date &&
+		a.Comment == b.Comment && a.VideoID == b.VideoID && a.Track == b.Track && a.TrackTotal == b.TrackTotal
+}
+
+func TestMetadataArgs(t *testing.T) {
+	got := metadataArgs(Metadata{Title: "Song", Album: "Live", VideoID: "dQw4w9WgXcQ", Track: 2, TrackTotal: 9})
+	want := []string{
+		"-metadata", "title=Song",
+		"-metadata", "album=Live",
+		"-metadata", videoIDTag + "=dQw4w9WgXcQ",
+		"-metadata", "track=2/9",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("metadataArgs = %v, want %v", got, want)
+	}
+
+	if got := metadataArgs(Metadata{}); got != nil {
+		t.Errorf("metadataArgs(empty) = %v, want none", got)
+	}
+}
+
+func TestCloseChapters(t *testing.T) {
+	chapters := []Chapter{
+		{Title: "Intro", Start: 0},
+		{Title: "Song", Start: time.Minute, End: 3 * time.Minute},
+		{Title: "Outro", Start: 4 * time.Minute},
+	}
+
+	got := closeChapters(chapters, 5*time.Minute)
+	want := []Chapter{
+		{Title: "Intro", Start: 0, End: time.Minute},
+		{Title: "Song", Start: time.Minute, End: 3 * time.Minute},
+		{Title: "Outro", Start: 4 * time.Minute, End: 5 * time.Minute},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("closeChapters = %+v, want %+v", got, want)
+	}
+	if chapters[0].End != 0 {
+		t.Error("closeChapters changed its input")
+	}
+}
+
+func TestWriteChapters(t *testing.T) {
+	var b strings.Builder
+	writeChapters(&b, []Chapter{
+		{Title: "Intro; part=1", Start: 0, End: 90 * time.Second},
+		// An unknown end is written as an empty chapter
+		{Title: "Outro", Start: 2 * time.Minute},
+	})
+
+	want := "[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=90000\ntitle=Intro\\; part\\=1\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=120000\nEND=120000\ntitle=Outro\n"
+	if b.String() != want {
+		t.Errorf("writeChapters =\n%s\nwant\n%s", b.String(), want)
+	}
+}
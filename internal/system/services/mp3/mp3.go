@@ -1,4 +1,5 @@
-// Package mp3 provides a service for downloading and converting YouTube videos to MP3 format.
+// Package mp3 provides a service for downloading and converting YouTube videos to MP3
+// and other audio formats.
 // It supports streaming conversion directly to an io.Writer, making it suitable for web servers,
 // file downloads, and other applications.
 package mp3
@@ -6,280 +7,349 @@ package mp3
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"net/url"
-	"os"
-	"os/exec"
-	"regexp"
 	"strings"
 	"time"
 
 	"github.com/kkdai/youtube/v2"
 )
 
-// VideoInfo contains metadata about a YouTube video.
-type VideoInfo struct {
-	Title    string
-	Author   string
-	Duration string
-	VideoID  string
-}
-
 // Service provides methods for downloading and converting YouTube videos.
 type Service struct {
-	client youtube.Client
+	extractors []Extractor
+	transcoder Transcoder
+
+	ytdlpPath  string
+	ffmpegPath string
+	httpClient *http.Client
+	tempDir    string
+	logger     *slog.Logger
+	timeouts   Timeouts
+	policy     URLPolicy
 }
 
-// New creates a new Service instance with custom HTTP client configuration.
-func New() *Service {
-	// Create HTTP client with proper timeout to avoid being blocked
-	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
+// New creates a new Service instance. Without options it extracts audio
+// with yt-dlp when available and falls back to the kkdai/youtube library.
+func New(options ...Option) *Service {
+	s := &Service{
+		logger: slog.New(slog.DiscardHandler),
+		policy: DefaultURLPolicy(),
+	}
+
+	for _, option := range options {
+		option(s)
 	}
 
-	return &Service{
-		client: youtube.Client{
-			HTTPClient: httpClient,
-		},
+	if s.logger == nil {
+		s.logger = slog.New(slog.DiscardHandler)
+	}
+	if s.httpClient == nil {
+		// Create HTTP client with proper timeout to avoid being blocked
+		s.httpClient = &http.Client{
+			Timeout: 30 * time.Second,
+		}
 	}
+
+	if s.httpClient.Transport == nil && !s.policy.AllowPrivateNetworks {
+		client := *s.httpClient
+		client.Transport = publicOnlyTransport()
+		s.httpClient = &client
+	}
+
+	if s.transcoder == nil {
+		s.transcoder = &FFmpeg{Path: s.ffmpegPath, TempDir: s.tempDir}
+	}
+
+	if s.extractors == nil {
+		s.extractors = []Extractor{
+			&YTDLPExtractor{Path: s.ytdlpPath, FFmpegPath: s.ffmpegPath},
+			&LibraryExtractor{
+				Client:  &youtube.Client{HTTPClient: s.httpClient},
+				TempDir: s.tempDir,
+			},
+		}
+	}
+
+	return s
 }
 
 // GetVideoInfo retrieves metadata about a YouTube video without downloading it.
-func (s *Service) GetVideoInfo(videoURL string) (*VideoInfo, error) {
-	// Extract clean video URL without playlist parameters
-	cleanURL := extractVideoURL(videoURL)
-
-	video, err := s.client.GetVideo(cleanURL)
+// The videoURL can be any YouTube URL understood by ParseVideoURL or a video ID.
+// Extractors are asked in order and the first successful answer is returned.
+// The lookup stops as soon as ctx is cancelled or its deadline passes.
+func (s *Service) GetVideoInfo(ctx context.Context, videoURL string) (*VideoInfo, error) {
+	ref, err := s.policy.ParseVideoURL(videoURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get video info: %w", err)
+		return nil, err
 	}
 
-	return &VideoInfo{
-		Title:    video.Title,
-		Author:   video.Author,
-		Duration: video.Duration.String(),
-		VideoID:  video.ID,
-	}, nil
-}
+	// Extractors get a clean URL without playlist parameters
+	cleanURL := ref.URL()
 
-// ConvertToWriter downloads a YouTube video and converts it to MP3,
-// streaming the output directly to the provided io.Writer.
-// The videoURL can be a full YouTube URL or video ID.
-// If opts is nil, DefaultOptions() will be used.
-func (s *Service) ConvertToWriter(ctx context.Context, videoURL string, w io.Writer, opts *Options) error {
-	if w == nil {
-		return fmt.Errorf("writer is required")
+	if len(s.extractors) == 0 {
+		return nil, ErrNoExtractors
 	}
 
-	// Extract clean video URL without playlist parameters
-	cleanURL := extractVideoURL(videoURL)
+	var errs BackendErrors
+	for _, extractor := range s.extractors {
+		info, err := s.infoWith(ctx, extractor, cleanURL)
+		if err == nil {
+			return info, nil
+		}
 
-	// First try using yt-dlp if available (more reliable)
-	if err := s.convertWithYTDLP(ctx, cleanURL, w, opts); err == nil {
-		return nil
+		s.logger.Warn("video info lookup failed", "backend", extractor.Name(), "error", err)
+		errs = append(errs, &BackendError{Backend: extractor.Name(), Err: err})
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("video info lookup cancelled: %w", ctx.Err())
+		}
 	}
 
-	// Fallback to kkdai/youtube library
-	return s.convertWithLibrary(ctx, cleanURL, w, opts)
+	return nil, fmt.Errorf("failed to get video info: %w", errs)
 }
 
-// extractVideoURL extracts the video ID from various YouTube URL formats
-// and returns a clean URL with just the video ID
-func extractVideoURL(videoURL string) string {
-	// If it's already just an ID (11 characters, alphanumeric, _, -)
-	if matched, _ := regexp.MatchString(`^[a-zA-Z0-9_-]{11}$`, videoURL); matched {
-		return "https://www.youtube.com/watch?v=" + videoURL
+func (s *Service) infoWith(ctx context.Context, extractor Extractor, videoURL string) (*VideoInfo, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Info)
+	defer cancel()
+
+	return extractor.Info(ctx, videoURL)
+}
+
+// ConvertToWriter downloads a YouTube video and converts it to the
+// requested format (MP3 by default), streaming the output directly to the provided io.Writer.
+// The videoURL can be any YouTube URL understood by ParseVideoURL or a
+// video ID. If opts is nil, DefaultOptions() will be used. A t= timestamp
+// in videoURL is the default Options.Start. Options are checked with
+// Options.Validate before anything is downloaded.
+//
+// Extractors are tried in order. The first part of the output is held back
+// until the current extractor has proven it works, so falling back to the
+// next one never writes into w twice. A failure after output reached w is
+// not retried and wraps ErrPartialOutput. When every extractor fails the
+// returned error is BackendErrors, which keeps the failure of each one.
+func (s *Service) ConvertToWriter(ctx context.Context, videoURL string, w io.Writer, opts *Options) (*Report, error) {
+	if w == nil {
+		return nil, fmt.Errorf("writer is required")
 	}
 
-	// Parse the URL
-	u, err := url.Parse(videoURL)
+	ref, err := s.policy.ParseVideoURL(videoURL)
 	if err != nil {
-		return videoURL
+		return nil, err
 	}
 
-	// Extract video ID from query parameters
-	videoID := u.Query().Get("v")
-	if videoID == "" {
-		return videoURL
+	resolved := withURLStart(normalizeOptions(opts), ref)
+	if !resolved.Format.Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, resolved.Format)
+	}
+	if err := resolved.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Return clean URL with just the video ID
-	return "https://www.youtube.com/watch?v=" + videoID
-}
+	// Extractors get a clean URL without playlist parameters
+	cleanURL := ref.URL()
 
-func (s *Service) convertWithYTDLP(ctx context.Context, videoURL string, w io.Writer, opts *Options) error {
-	resolved := normalizeOptions(opts)
+	if resolved.MaxSizeBytes > 0 {
+		// The bitrate depends on the duration, refuse before downloading
+		// when the video is too long for the limit
+		if resolved.Info == nil {
+			if resolved.Info, err = s.GetVideoInfo(ctx, cleanURL); err != nil {
+				return nil, err
+			}
+		}
+		if resolved, err = fitToSize(resolved, clipLength(resolved, resolved.Info.Duration)); err != nil {
+			return nil, err
+		}
+	}
 
-	// Check if yt-dlp is available
-	if _, err := exec.LookPath("yt-dlp"); err != nil {
-		return fmt.Errorf("yt-dlp not found")
+	if len(s.extractors) == 0 {
+		return nil, ErrNoExtractors
 	}
 
-	cmd := exec.CommandContext(ctx, "yt-dlp",
-		"--no-warnings",
-		"--quiet",
-		"--no-playlist",
-		"-f", "bestaudio[ext=m4a]/bestaudio",
-		"-o", "-",
-		"--ffmpeg-location", "ffmpeg",
-		videoURL,
-	)
+	var errs BackendErrors
+	for i, extractor := range s.extractors {
+		out := &outputGuard{w: w}
+		if i == len(s.extractors)-1 {
+			// Nothing left to fall back to, stream right away
+			out.commit()
+		}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
-	}
+		s.logger.Debug("converting video", "url", cleanURL, "backend", extractor.Name())
+		report, err := s.convertWith(ctx, extractor, cleanURL, out, resolved)
+		if err == nil {
+			if err := out.commit(); err != nil {
+				return nil, err
+			}
+			report.Backend, report.Bytes = extractor.Name(), out.written
+			return report, nil
+		}
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start yt-dlp: %w", err)
+		s.logger.Warn("conversion failed", "url", cleanURL, "backend", extractor.Name(), "error", err)
+		errs = append(errs, &BackendError{Backend: extractor.Name(), Err: err})
+		if out.written > 0 {
+			return nil, fmt.Errorf("%w: %w", ErrPartialOutput, errs)
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("conversion cancelled: %w", ctx.Err())
+		}
 	}
 
-	// Convert the downloaded audio with ffmpeg
-	ffmpegCmd := exec.CommandContext(ctx, "ffmpeg",
-		"-hide_banner",
-		"-loglevel", "error",
-		"-i", "pipe:0",
-		"-vn",
-		"-ar", fmt.Sprintf("%d", resolved.SampleRate),
-		"-ac", fmt.Sprintf("%d", resolved.Channels),
-		"-b:a", resolved.Bitrate,
-		"-f", resolved.Format,
-		"-",
-	)
+	return nil, errs
+}
 
-	ffmpegCmd.Stdin = stdout
-	ffmpegCmd.Stdout = w
+func (s *Service) convertWith(ctx context.Context, extractor Extractor, videoURL string, w io.Writer, opts Options) (*Report, error) {
+	// The download context lives until the stream is closed, streaming
+	// extractors keep downloading while the audio is encoded.
+	downloadCtx, cancel := withTimeout(ctx, s.timeouts.Download)
+	defer cancel()
+
+	progress := opts.Progress
+	var tracker *progressTracker
+	if progress != nil {
+		tracker = newProgressTracker(progress, extractor.Name())
+		opts.Progress = tracker.update
+	}
 
-	var stderr bytes.Buffer
-	ffmpegCmd.Stderr = &stderr
+	stream, err := extractor.Open(downloadCtx, videoURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
 
-	ffmpegErrChan := make(chan error, 1)
-	go func() {
-		ffmpegErrChan <- ffmpegCmd.Run()
-	}()
+	info := s.streamInfo(downloadCtx, extractor, videoURL, stream, opts.Info)
+	total := stream.Duration
+	if total == 0 && info != nil {
+		total = info.Duration
+	}
+	opts = s.prepare(downloadCtx, opts, stream.Format, info, total, videoURL)
+
+	if tracker != nil {
+		if total > 0 {
+			tracker.setDuration(clipLength(opts, total))
+		} else {
+			// Encode progress needs the video length, look it up
+			// while the stream is already downloading.
+			go func() {
+				if info, err := s.infoWith(downloadCtx, extractor, videoURL); err == nil {
+					tracker.setDuration(clipLength(opts, info.Duration))
+				}
+			}()
+		}
+	}
 
-	// Wait for both processes
-	cmdErr := cmd.Wait()
-	ffmpegErr := <-ffmpegErrChan
+	encodeCtx, cancelEncode := withTimeout(downloadCtx, s.timeouts.Encode)
+	defer cancelEncode()
 
-	if ffmpegErr != nil {
-		errMsg := stderr.String()
-		if errMsg != "" {
-			return fmt.Errorf("ffmpeg conversion failed: %s", strings.TrimSpace(errMsg))
+	report, err := s.encode(encodeCtx, stream, w, opts, stream.Format, stream.Start, videoURL)
+	if err != nil {
+		// A download that failed left the encoder without input, its
+		// error explains the encoder's
+		if closeErr := stream.Close(); closeErr != nil {
+			return nil, errors.Join(closeErr, err)
 		}
-		return fmt.Errorf("ffmpeg conversion failed: %w", ffmpegErr)
+		return nil, err
 	}
 
-	if cmdErr != nil {
-		return fmt.Errorf("yt-dlp failed: %w", cmdErr)
+	// Closing the stream reports download errors, such as yt-dlp failing
+	if err := stream.Close(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	if tracker != nil {
+		tracker.flush()
+	}
+
+	report.Options.Progress = progress
+	return report, nil
 }
 
-func (s *Service) convertWithLibrary(ctx context.Context, videoURL string, w io.Writer, opts *Options) error {
-	resolved := normalizeOptions(opts)
+// streamInfo returns the video described by stream, or known when the
+// extractor did not describe it, looking it up when neither is set. It
+// returns nil when the lookup fails.
+func (s *Service) streamInfo(ctx context.Context, extractor Extractor, videoURL string, stream *Stream, known *VideoInfo) *VideoInfo {
+	if stream.Info != nil {
+		return stream.Info
+	}
+	if known != nil {
+		return known
+	}
 
-	video, err := s.client.GetVideo(videoURL)
+	// Tags need the title and channel before encoding starts
+	info, err := s.infoWith(ctx, extractor, videoURL)
 	if err != nil {
-		return fmt.Errorf("failed to get video info: %w", err)
+		s.logger.Debug("video info lookup for tags failed", "backend", extractor.Name(), "error", err)
+		return nil
 	}
+	return info
+}
 
-	format := s.selectBestAudioFormat(video)
-	if format == nil {
-		return fmt.Errorf("no audio formats available")
+// prepare resolves the settings that depend on the source: automatic
+// values from the stream the extractor picked, the tags and chapters of
+// the video, which is total long, and the cover art.
+func (s *Service) prepare(ctx context.Context, opts Options, source AudioFormat, info *VideoInfo, total time.Duration, videoURL string) Options {
+	autoBitrate := opts.Bitrate == AutoBitrate
+	opts = resolveAuto(opts, source)
+	if autoBitrate && opts.MaxSizeBytes > 0 {
+		opts = capToSize(opts, clipLength(opts, total))
 	}
+	opts.Metadata = opts.Metadata.withDefaults(info, videoURL, opts.Chapters)
+	opts.Metadata.Chapters = trimChapters(closeChapters(opts.Metadata.Chapters, total), opts.Start, opts.End)
+	return s.withCover(ctx, opts, info)
+}
 
-	// YouTube blocks direct streaming, so we download to a temp file first
-	stream, _, err := s.client.GetStream(video, format)
-	if err != nil {
-		return fmt.Errorf("failed to get video stream: %w", err)
+// encode writes the audio in src, which begins at offset in the video, to
+// w, remuxing it instead of encoding when opts.Copy allows it.
+func (s *Service) encode(ctx context.Context, src io.Reader, w io.Writer, opts Options, source AudioFormat, offset time.Duration, videoURL string) (*Report, error) {
+	report := &Report{Source: source, SourceCodec: normalizeCodec(source.Codec)}
+	remuxer, canRemux := s.transcoder.(Remuxer)
+	// Normalizing changes the audio, so it cannot be copied
+	report.Copied = opts.Copy && !opts.Loudness.Enabled() && canRemux && opts.Format.canCopy(report.SourceCodec) && fitsSize(opts, source)
+	if opts.Copy && !report.Copied {
+		s.logger.Debug("stream copy not possible, encoding", "url", videoURL, "codec", report.SourceCodec, "format", opts.Format)
 	}
-	defer stream.Close()
 
-	// Download to temp file
-	tempFile, err := os.CreateTemp("", "gomp3-*.tmp")
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+	var err error
+	clip := shiftClip(opts, offset)
+	switch {
+	case report.Copied:
+		err = remuxer.Remux(ctx, src, w, clip)
+	case opts.Loudness.Enabled():
+		normalizer, ok := s.transcoder.(Normalizer)
+		if !ok {
+			return nil, fmt.Errorf("%w: the transcoder cannot normalize loudness", ErrInvalidOptions)
+		}
+		report.Loudness, err = normalizer.Normalize(ctx, src, w, clip)
+	default:
+		err = s.transcoder.Transcode(ctx, src, w, clip)
 	}
-	tempPath := tempFile.Name()
-	defer os.Remove(tempPath)
-	defer tempFile.Close()
-
-	// Copy stream to temp file
-	_, err = io.Copy(tempFile, stream)
 	if err != nil {
-		return fmt.Errorf("failed to download video (YouTube may be blocking this request). Try installing yt-dlp: brew install yt-dlp")
-	}
-	tempFile.Close()
-
-	// Convert using ffmpeg
-	cmd := exec.CommandContext(ctx, "ffmpeg",
-		"-hide_banner",
-		"-loglevel", "error",
-		"-i", tempPath,
-		"-vn",
-		"-ar", fmt.Sprintf("%d", resolved.SampleRate),
-		"-ac", fmt.Sprintf("%d", resolved.Channels),
-		"-b:a", resolved.Bitrate,
-		"-f", resolved.Format,
-		"-",
-	)
-
-	cmd.Stdout = w
-
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		errMsg := stderr.String()
-		if errMsg != "" {
-			return fmt.Errorf("ffmpeg conversion failed: %s", strings.TrimSpace(errMsg))
-		}
-		return fmt.Errorf("ffmpeg conversion failed: %w", err)
+		return nil, err
 	}
 
-	return nil
+	report.Options = opts
+	return report, nil
 }
 
-// Convert downloads a YouTube video, converts it to MP3, and returns the audio data.
+// Convert downloads a YouTube video, converts it to the requested format
+// (MP3 by default), and returns the audio data.
 // This is a convenience method that buffers the output in memory.
 // For large files or server applications, use ConvertToWriter instead.
 func (s *Service) Convert(ctx context.Context, videoURL string, opts *Options) ([]byte, error) {
 	var buf bytes.Buffer
-	if err := s.ConvertToWriter(ctx, videoURL, &buf, opts); err != nil {
+	if _, err := s.ConvertToWriter(ctx, videoURL, &buf, opts); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
 
-func (s *Service) selectBestAudioFormat(video *youtube.Video) *youtube.Format {
-	formats := video.Formats.Type("audio")
-	if len(formats) == 0 {
-		formats = video.Formats.WithAudioChannels()
-	}
-
-	if len(formats) == 0 {
-		return nil
-	}
-
-	var bestFormat *youtube.Format
-	for i := range formats {
-		f := &formats[i]
-		if f.QualityLabel == "" {
-			if bestFormat == nil || f.Bitrate < bestFormat.Bitrate {
-				bestFormat = f
-			}
-		}
-	}
-
-	if bestFormat == nil {
-		bestFormat = &formats[0]
+// withTimeout is like context.WithTimeout but treats zero as no limit.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
-
-	return bestFormat
+	return context.WithTimeout(ctx, timeout)
 }
 
 func normalizeOptions(opts *Options) Options {
@@ -297,9 +367,38 @@ func normalizeOptions(opts *Options) Options {
 	if opts.Bitrate != "" {
 		resolved.Bitrate = opts.Bitrate
 	}
+	if opts.Mode != "" {
+		resolved.Mode = opts.Mode
+	}
+	resolved.Quality = opts.Quality
 	if opts.Format != "" {
 		resolved.Format = opts.Format
+		if rate := formatSpecs[opts.Format].sampleRate; rate != 0 && opts.SampleRate == 0 {
+			resolved.SampleRate = rate
+		}
+		if mode := formatSpecs[opts.Format].mode; mode != "" && opts.Mode == "" {
+			resolved.Mode = mode
+		}
+	}
+	if resolved.Format == FormatMP3 && resolved.Mode == ModeVBR && resolved.Quality == "" {
+		resolved.Quality = "V4"
+	}
+	resolved.Source = opts.Source
+	if resolved.Source.Prefer == "" {
+		resolved.Source.Prefer = SourceBest
+	}
+	resolved.Start, resolved.End, resolved.StartSet = opts.Start, opts.End, opts.StartSet
+	resolved.Metadata = opts.Metadata
+	resolved.Info = opts.Info
+	if opts.Chapters != "" {
+		resolved.Chapters = opts.Chapters
 	}
+	resolved.Cover = opts.Cover
+	resolved.SquareCover = opts.SquareCover
+	resolved.MaxSizeBytes = opts.MaxSizeBytes
+	resolved.Loudness = opts.Loudness.withDefaults()
+	resolved.Copy = opts.Copy
+	resolved.Progress = opts.Progress
 
 	return *resolved
 }
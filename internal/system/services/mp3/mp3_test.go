@@ -0,0 +1,248 @@
+package mp3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testVideoURL = "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+
+// fakeExtractor serves audio from memory.
+type fakeExtractor struct {
+	name  string
+	audio string
+	err   error
+	// closeErr is returned when the stream is closed
+	closeErr error
+	// opened counts the calls to Open
+	opened int
+}
+
+func (e *fakeExtractor) Name() string {
+	return e.name
+}
+
+func (e *fakeExtractor) Info(ctx context.Context, videoURL string) (*VideoInfo, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &VideoInfo{Title: "Test video", Author: "Test channel"}, nil
+}
+
+func (e *fakeExtractor) Open(ctx context.Context, videoURL string, opts Options) (*Stream, error) {
+	e.opened++
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &Stream{
+		ReadCloser: fakeStream{strings.NewReader(e.audio), e.closeErr},
+		Size:       int64(len(e.audio)),
+		Format:     AudioFormat{Codec: "opus", Bitrate: 160000},
+		Info:       &VideoInfo{Title: "Test video", Author: "Test channel"},
+	}, nil
+}
+
+type fakeStream struct {
+	io.Reader
+	err error
+}
+
+func (s fakeStream) Close() error {
+	return s.err
+}
+
+// fakeTranscoder copies the source to the output. Sources starting with
+// "fail" make it write written bytes and fail.
+type fakeTranscoder struct {
+	written int
+	// remuxed is set when Remux was used instead of Transcode
+	remuxed bool
+}
+
+var errEncode = errors.New("encode failed")
+
+func (f *fakeTranscoder) Transcode(ctx context.Context, src io.Reader, w io.Writer, opts Options) error {
+	audio, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(audio, []byte("fail")) {
+		w.Write(bytes.Repeat([]byte{0}, f.written))
+		return errEncode
+	}
+	_, err = w.Write(audio)
+	return err
+}
+
+func (f *fakeTranscoder) Remux(ctx context.Context, src io.Reader, w io.Writer, opts Options) error {
+	f.remuxed = true
+	return f.Transcode(ctx, src, w, opts)
+}
+
+func TestConvertToWriter(t *testing.T) {
+	extractor := &fakeExtractor{name: "fake", audio: "audio"}
+	svc := New(WithExtractors(extractor), WithTranscoder(&fakeTranscoder{}))
+
+	var out bytes.Buffer
+	report, err := svc.ConvertToWriter(t.Context(), testVideoURL, &out, nil)
+	if err != nil {
+		t.Fatalf("ConvertToWriter error: %v", err)
+	}
+	if out.String() != "audio" {
+		t.Errorf("output = %q, want %q", out.String(), "audio")
+	}
+	if report.Backend != "fake" || report.Bytes != 5 {
+		t.Errorf("report backend %q, %d bytes, want fake, 5 bytes", report.Backend, report.Bytes)
+	}
+	if report.Options.Metadata.Title != "Test video" {
+		t.Errorf("title tag = %q, want the video title", report.Options.Metadata.Title)
+	}
+}
+
+func TestConvertToWriterFallback(t *testing.T) {
+	tests := []struct {
+		name  string
+		first *fakeExtractor
+	}{
+		{"open fails", &fakeExtractor{name: "first", err: ErrThrottled}},
+		{"encode fails before output", &fakeExtractor{name: "first", audio: "fail"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			second := &fakeExtractor{name: "second", audio: "audio"}
+			svc := New(WithExtractors(tt.first, second), WithTranscoder(&fakeTranscoder{written: 100}))
+
+			var out bytes.Buffer
+			report, err := svc.ConvertToWriter(t.Context(), testVideoURL, &out, nil)
+			if err != nil {
+				t.Fatalf("ConvertToWriter error: %v", err)
+			}
+			if report.Backend != "second" {
+				t.Errorf("backend = %q, want second", report.Backend)
+			}
+			// The output of the failed attempt is held back and dropped
+			if out.String() != "audio" {
+				t.Errorf("output = %q, want only the second extractor's audio", out.String())
+			}
+		})
+	}
+}
+
+func TestConvertToWriterPartialOutput(t *testing.T) {
+	first := &fakeExtractor{name: "first", audio: "fail"}
+	second := &fakeExtractor{name: "second", audio: "audio"}
+	// More than holdBackSize reaches the writer before the failure
+	svc := New(WithExtractors(first, second), WithTranscoder(&fakeTranscoder{written: holdBackSize + 1}))
+
+	var out bytes.Buffer
+	_, err := svc.ConvertToWriter(t.Context(), testVideoURL, &out, nil)
+	if !errors.Is(err, ErrPartialOutput) || !errors.Is(err, errEncode) {
+		t.Fatalf("error = %v, want ErrPartialOutput wrapping the encode error", err)
+	}
+	if second.opened != 0 {
+		t.Error("fell back to the second extractor after output was written")
+	}
+}
+
+func TestConvertToWriterLastPartialOutput(t *testing.T) {
+	only := &fakeExtractor{name: "only", audio: "fail"}
+	// The last extractor streams right away, a few bytes are enough
+	svc := New(WithExtractors(only), WithTranscoder(&fakeTranscoder{written: 10}))
+
+	var out bytes.Buffer
+	_, err := svc.ConvertToWriter(t.Context(), testVideoURL, &out, nil)
+	if !errors.Is(err, ErrPartialOutput) {
+		t.Fatalf("error = %v, want ErrPartialOutput", err)
+	}
+	if out.Len() != 10 {
+		t.Errorf("output = %d bytes, want 10", out.Len())
+	}
+}
+
+func TestConvertToWriterAllFail(t *testing.T) {
+	first := &fakeExtractor{name: "first", err: ErrThrottled}
+	second := &fakeExtractor{name: "second", err: ErrVideoUnavailable}
+	svc := New(WithExtractors(first, second), WithTranscoder(&fakeTranscoder{}))
+
+	_, err := svc.ConvertToWriter(t.Context(), testVideoURL, io.Discard, nil)
+
+	var errs BackendErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("error = %v, want BackendErrors for both extractors", err)
+	}
+	if !errors.Is(err, ErrThrottled) || !errors.Is(err, ErrVideoUnavailable) {
+		t.Errorf("error = %v, want both causes", err)
+	}
+	// The last extractor streams right away but wrote nothing
+	if errors.Is(err, ErrPartialOutput) {
+		t.Errorf("error = %v, want no ErrPartialOutput before any output", err)
+	}
+}
+
+func TestConvertToWriterDownloadError(t *testing.T) {
+	// The download fails before any audio, so the encoder fails too
+	only := &fakeExtractor{name: "only", audio: "fail", closeErr: ErrThrottled}
+	svc := New(WithExtractors(only), WithTranscoder(&fakeTranscoder{}))
+
+	_, err := svc.ConvertToWriter(t.Context(), testVideoURL, io.Discard, nil)
+	if !errors.Is(err, ErrThrottled) || !errors.Is(err, errEncode) {
+		t.Errorf("error = %v, want the download error along with the encode error", err)
+	}
+}
+
+func TestConvertToWriterRejectsInput(t *testing.T) {
+	extractor := &fakeExtractor{name: "fake", audio: "audio"}
+	svc := New(WithExtractors(extractor), WithTranscoder(&fakeTranscoder{}))
+
+	if _, err := svc.ConvertToWriter(t.Context(), "https://vimeo.com/123", io.Discard, nil); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("other host error = %v, want ErrInvalidURL", err)
+	}
+	if _, err := svc.ConvertToWriter(t.Context(), testVideoURL, io.Discard, &Options{Channels: 6}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("invalid options error = %v, want ErrInvalidOptions", err)
+	}
+	if extractor.opened != 0 {
+		t.Error("rejected input reached the extractor")
+	}
+}
+
+func TestWithNoExtractors(t *testing.T) {
+	svc := New(WithExtractors(), WithTranscoder(&fakeTranscoder{}))
+
+	if _, err := svc.ConvertToWriter(t.Context(), testVideoURL, io.Discard, nil); !errors.Is(err, ErrNoExtractors) {
+		t.Errorf("ConvertToWriter error = %v, want ErrNoExtractors", err)
+	}
+	if _, err := svc.GetVideoInfo(t.Context(), testVideoURL); !errors.Is(err, ErrNoExtractors) {
+		t.Errorf("GetVideoInfo error = %v, want ErrNoExtractors", err)
+	}
+}
+
+func TestConvertToWriterCopy(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   bool
+	}{
+		// The fake source is Opus
+		{FormatOpus, true},
+		{FormatWebM, true},
+		{FormatM4A, false},
+		{FormatMP3, false},
+	}
+
+	for _, tt := range tests {
+		transcoder := &fakeTranscoder{}
+		svc := New(WithExtractors(&fakeExtractor{name: "fake", audio: "audio"}), WithTranscoder(transcoder))
+
+		report, err := svc.ConvertToWriter(t.Context(), testVideoURL, io.Discard, &Options{Format: tt.format, Copy: true})
+		if err != nil {
+			t.Fatalf("%s: ConvertToWriter error: %v", tt.format, err)
+		}
+		if report.Copied != tt.want || transcoder.remuxed != tt.want {
+			t.Errorf("%s: copied %v, remuxed %v, want %v", tt.format, report.Copied, transcoder.remuxed, tt.want)
+		}
+	}
+}
@@ -1,23 +1,112 @@
 package mp3
 
-// Options configures the MP3 conversion parameters.
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Options configures the output format and encoding of a conversion.
 type Options struct {
-	// SampleRate is the audio sample rate in Hz (default: 22050)
+	// SampleRate is the audio sample rate in Hz, or Auto for the source
+	// rate (default: 22050, 24000 for Opus)
 	SampleRate int
-	// Channels is the number of audio channels: 1 for mono, 2 for stereo (default: 1)
+	// Channels is the number of audio channels: 1 for mono, 2 for stereo,
+	// or Auto for the source channels (default: 1)
 	Channels int
-	// Bitrate is the audio bitrate (default: "64k")
+	// Bitrate is the audio bitrate, or AutoBitrate for the source bitrate
+	// (default: "64k"). In ModeVBR it is ignored for MP3 and used as the
+	// target bitrate for Opus.
 	Bitrate string
-	// Format is the output format, typically "mp3" (default: "mp3")
-	Format string
+	// Mode is the bitrate mode (default: ModeCBR, ModeVBR for Opus)
+	Mode BitrateMode
+	// Quality is the LAME VBR level used for MP3 in ModeVBR, from "V0"
+	// (best, about 245k) to "V9" (smallest, about 65k) (default: "V4")
+	Quality string
+	// Format is the output format (default: FormatMP3)
+	Format Format
+	// Source chooses which audio stream is downloaded (default: the
+	// highest bitrate)
+	Source SourcePolicy
+	// Start and End cut the output to that part of the video (default: from
+	// the t= timestamp of the video URL to the end). Chapters are cut and
+	// shifted to match. yt-dlp downloads only that part, the kkdai/youtube
+	// extractor downloads the whole stream.
+	Start time.Duration
+	End   time.Duration
+	// StartSet makes Start apply even when it is zero, so a URL with a t=
+	// timestamp can still be converted from the start of the video.
+	StartSet bool
+	// Metadata overrides the tags taken from the video, see Metadata.
+	Metadata Metadata
+	// Chapters picks where the chapters come from when Metadata.Chapters
+	// is nil: YouTube's own chapters, a tracklist in the description or
+	// none (default: ChaptersOfficial)
+	Chapters ChapterSource
+	// Cover embeds the video thumbnail as cover art, see Metadata.Cover.
+	Cover bool
+	// SquareCover center-crops the cover art to a square, the shape music
+	// players show it in.
+	SquareCover bool
+	// MaxSizeBytes, when set, replaces Bitrate with the highest bitrate
+	// whose output fits in this many bytes for the video's duration.
+	// Conversions that cannot fit fail with ErrTooLarge before anything is
+	// downloaded. It needs a lossy format with a fixed or average bitrate.
+	MaxSizeBytes int64
+	// Loudness normalizes the output to a target loudness in two passes of
+	// ffmpeg's EBU R128 loudnorm filter, see ParseLoudness for presets
+	// (default: off). The first pass measures the source, the measurement
+	// is returned in Report.Loudness. It rules out Copy.
+	Loudness Loudness
+	// Copy keeps the source audio without re-encoding when its codec fits
+	// the Format container: AAC for FormatM4A, Opus for FormatOpus and
+	// FormatWebM, and it is within MaxSizeBytes. Other sources are encoded
+	// with the settings above.
+	Copy bool
+	// Info is the video as returned by GetVideoInfo, when the caller has
+	// already looked it up. Conversions use it instead of looking the
+	// video up again, and extractors pick the source stream from its
+	// AudioFormats. It must describe the video being converted.
+	Info *VideoInfo
+	// Progress, when set, receives download and encode progress updates
+	Progress ProgressFunc
 }
 
-// DefaultOptions returns the default conversion options.
+// DefaultOptions returns the default conversion options, which are tuned
+// for speech. See Preset for higher quality settings.
 func DefaultOptions() *Options {
 	return &Options{
 		SampleRate: 22050,
 		Channels:   1,
 		Bitrate:    "64k",
-		Format:     "mp3",
+		Mode:       ModeCBR,
+		Format:     FormatMP3,
+		Source:     SourcePolicy{Prefer: SourceBest},
+		Chapters:   ChaptersOfficial,
+	}
+}
+
+// BitrateMode selects how the encoder spends bits over time.
+type BitrateMode string
+
+const (
+	// ModeCBR encodes at a constant Bitrate.
+	ModeCBR BitrateMode = "cbr"
+	// ModeVBR lets the bitrate follow the complexity of the audio. MP3 uses
+	// the LAME level in Quality, Opus treats Bitrate as the average.
+	ModeVBR BitrateMode = "vbr"
+	// ModeCVBR is Opus constrained VBR, which keeps the bitrate close to
+	// Bitrate over short windows.
+	ModeCVBR BitrateMode = "cvbr"
+)
+
+// ParseBitrateMode returns the BitrateMode for a name such as "vbr".
+func ParseBitrateMode(name string) (BitrateMode, error) {
+	mode := BitrateMode(strings.ToLower(strings.TrimSpace(name)))
+	switch mode {
+	case ModeCBR, ModeVBR, ModeCVBR:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown bitrate mode %q, use cbr, vbr or cvbr", name)
 	}
 }
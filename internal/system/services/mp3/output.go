@@ -0,0 +1,44 @@
+package mp3
+
+import (
+	"bytes"
+	"io"
+)
+
+// holdBackSize is how much encoded output a backend must produce before it
+// reaches the caller's writer. Failures before that point are recovered by
+// discarding the buffer and falling back to the next extractor.
+const holdBackSize = 256 << 10
+
+// outputGuard holds back the beginning of the output until the backend has
+// proven it works, so a failing backend never leaves partial data behind.
+type outputGuard struct {
+	w         io.Writer
+	buf       bytes.Buffer
+	committed bool
+	written   int64
+}
+
+func (g *outputGuard) Write(p []byte) (int, error) {
+	if g.committed {
+		n, err := g.w.Write(p)
+		g.written += int64(n)
+		return n, err
+	}
+
+	g.buf.Write(p)
+	if g.buf.Len() >= holdBackSize {
+		if err := g.commit(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// commit writes the held back output and passes further writes through.
+func (g *outputGuard) commit() error {
+	g.committed = true
+	n, err := g.buf.WriteTo(g.w)
+	g.written += n
+	return err
+}
@@ -0,0 +1,179 @@
+package mp3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Names of the built-in presets.
+const (
+	// PresetVoice is tuned for speech: 64k mono MP3 at 22050 Hz, the same
+	// as DefaultOptions.
+	PresetVoice = "voice"
+	// PresetStandard is 128k stereo MP3 at 44100 Hz.
+	PresetStandard = "standard"
+	// PresetHigh is 320k stereo MP3 at 44100 Hz.
+	PresetHigh = "high"
+	// PresetHighVBR is LAME V0 stereo MP3 at 44100 Hz, averaging about
+	// 245k but smaller than PresetHigh on simple material.
+	PresetHighVBR = "high-vbr"
+	// PresetArchive is lossless stereo FLAC at 48000 Hz.
+	PresetArchive = "archive"
+)
+
+var (
+	presetsMu sync.RWMutex
+	presets   = map[string]Options{
+		PresetVoice:    *DefaultOptions(),
+		PresetStandard: {Format: FormatMP3, Bitrate: "128k", SampleRate: 44100, Channels: 2},
+		PresetHigh:     {Format: FormatMP3, Bitrate: "320k", SampleRate: 44100, Channels: 2},
+		PresetHighVBR:  {Format: FormatMP3, Mode: ModeVBR, Quality: "V0", SampleRate: 44100, Channels: 2},
+		PresetArchive:  {Format: FormatFLAC, SampleRate: 48000, Channels: 2},
+	}
+	// presetNames keeps the presets in registration order for listings.
+	presetNames = []string{PresetVoice, PresetStandard, PresetHigh, PresetHighVBR, PresetArchive}
+)
+
+// Preset returns a copy of the named preset. Names are case insensitive.
+// Errors wrap ErrUnknownPreset.
+func Preset(name string) (Options, error) {
+	presetsMu.RLock()
+	defer presetsMu.RUnlock()
+
+	opts, ok := presets[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Options{}, fmt.Errorf("%w: %q", ErrUnknownPreset, name)
+	}
+	return opts, nil
+}
+
+// WithFormat returns o set to produce format f. Settings f's encoder does
+// not take, such as a 22050 Hz sample rate for Opus or VBR mode for M4A,
+// are cleared so the defaults of f apply, which lets a preset be used
+// with any format.
+func (o Options) WithFormat(f Format) Options {
+	o.Format = f
+	limits, ok := formatLimits[f]
+	if !ok {
+		return o
+	}
+
+	if o.SampleRate > 0 && !limits.acceptsSampleRate(o.SampleRate) {
+		o.SampleRate = 0
+	}
+	if o.Channels > limits.maxChannels {
+		o.Channels = 0
+	}
+	if o.Mode != "" && !slices.Contains(limits.modes, o.Mode) {
+		o.Mode = ""
+	}
+	// Quality is a LAME level
+	if f != FormatMP3 {
+		o.Quality = ""
+	}
+
+	// The bitrate range depends on the sample rate settled above
+	var fieldErrs ValidationErrors
+	if errors.As(o.Validate(), &fieldErrs) && slices.ContainsFunc(fieldErrs, func(fe *FieldError) bool { return fe.Field == "Bitrate" }) {
+		o.Bitrate = ""
+	}
+	return o
+}
+
+// Presets returns the names of all presets, built-in ones first and then
+// user-defined ones in the order they were registered.
+func Presets() []string {
+	presetsMu.RLock()
+	defer presetsMu.RUnlock()
+
+	return slices.Clone(presetNames)
+}
+
+// RegisterPreset adds a user-defined preset or replaces an existing one.
+// The options are validated with Options.Validate and their Progress
+// callback is dropped.
+func RegisterPreset(name string, opts Options) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return fmt.Errorf("preset name is required")
+	}
+
+	opts.Progress = nil
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("preset %q: %w", name, err)
+	}
+
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+
+	if _, ok := presets[name]; !ok {
+		presetNames = append(presetNames, name)
+	}
+	presets[name] = opts
+	return nil
+}
+
+// presetFile is a single preset in the JSON read by LoadPresets.
+type presetFile struct {
+	Format     string `json:"format"`
+	Bitrate    string `json:"bitrate"`
+	SampleRate int    `json:"sample_rate"`
+	Channels   int    `json:"channels"`
+	Mode       string `json:"mode"`
+	Quality    string `json:"quality"`
+	Copy       bool   `json:"copy"`
+}
+
+// LoadPresets registers the presets in a JSON object that maps preset
+// names to their settings, for example:
+//
+//	{
+//	  "podcast": {"format": "mp3", "bitrate": "96k", "sample_rate": 44100, "channels": 1},
+//	  "music": {"format": "opus", "bitrate": "160k", "mode": "vbr", "channels": 2}
+//	}
+//
+// Omitted settings use the defaults.
+func LoadPresets(r io.Reader) error {
+	var file map[string]presetFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return fmt.Errorf("failed to read presets: %w", err)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(file)) {
+		p := file[name]
+
+		opts := Options{
+			Bitrate:    p.Bitrate,
+			SampleRate: p.SampleRate,
+			Channels:   p.Channels,
+			Quality:    p.Quality,
+			Copy:       p.Copy,
+		}
+		if p.Mode != "" {
+			mode, err := ParseBitrateMode(p.Mode)
+			if err != nil {
+				return fmt.Errorf("preset %q: %w", name, err)
+			}
+			opts.Mode = mode
+		}
+		if p.Format != "" {
+			format, err := ParseFormat(p.Format)
+			if err != nil {
+				return fmt.Errorf("preset %q: %w", name, err)
+			}
+			opts.Format = format
+		}
+
+		if err := RegisterPreset(name, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package mp3
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPresetWithFormat(t *testing.T) {
+	for _, name := range []string{PresetVoice, PresetStandard, PresetHigh, PresetHighVBR, PresetArchive} {
+		preset, err := Preset(name)
+		if err != nil {
+			t.Fatalf("Preset(%q) error: %v", name, err)
+		}
+
+		for _, format := range Formats() {
+			opts := preset.WithFormat(format)
+			if opts.Format != format {
+				t.Errorf("%s as %s: Format = %s", name, format, opts.Format)
+			}
+			if err := opts.Validate(); err != nil {
+				t.Errorf("%s as %s: %v", name, format, err)
+			}
+		}
+	}
+}
+
+func TestWithFormatKeepsValidSettings(t *testing.T) {
+	high, _ := Preset(PresetHigh)
+
+	opus := high.WithFormat(FormatOpus)
+	if opus.SampleRate != 0 || opus.Bitrate != "320k" || opus.Channels != 2 {
+		t.Errorf("high as opus = %s at %d Hz, %d channels, want 320k at the opus default, 2 channels", opus.Bitrate, opus.SampleRate, opus.Channels)
+	}
+
+	vbr, _ := Preset(PresetHighVBR)
+	m4a := vbr.WithFormat(FormatM4A)
+	if m4a.Mode != "" || m4a.Quality != "" || m4a.SampleRate != 44100 {
+		t.Errorf("high-vbr as m4a = mode %q, quality %q at %d Hz, want cbr at 44100 Hz", m4a.Mode, m4a.Quality, m4a.SampleRate)
+	}
+}
+
+func TestPreset(t *testing.T) {
+	opts, err := Preset(" High ")
+	if err != nil || opts.Bitrate != "320k" {
+		t.Errorf("Preset(\" High \") = %+v, %v, want the high preset", opts, err)
+	}
+	if _, err := Preset("loud"); !errors.Is(err, ErrUnknownPreset) {
+		t.Errorf("Preset(\"loud\") error = %v, want ErrUnknownPreset", err)
+	}
+}
+
+func TestLoadPresets(t *testing.T) {
+	err := LoadPresets(strings.NewReader(`{"test-music": {"format": "ogg", "bitrate": "160k", "mode": "vbr", "channels": 2}}`))
+	if err != nil {
+		t.Fatalf("LoadPresets error: %v", err)
+	}
+
+	opts, err := Preset("test-music")
+	if err != nil {
+		t.Fatalf("Preset error: %v", err)
+	}
+	if opts.Format != FormatOpus || opts.Bitrate != "160k" || opts.Mode != ModeVBR || opts.Channels != 2 {
+		t.Errorf("loaded preset = %+v", opts)
+	}
+
+	if err := LoadPresets(strings.NewReader(`{"test-bad": {"channels": 6}}`)); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("invalid preset error = %v, want ErrInvalidOptions", err)
+	}
+}
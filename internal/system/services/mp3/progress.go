@@ -0,0 +1,138 @@
+package mp3
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Phase is the stage of a conversion a Progress update refers to.
+type Phase string
+
+const (
+	// PhaseDownload reports bytes of source audio downloaded.
+	PhaseDownload Phase = "download"
+	// PhaseEncode reports the position ffmpeg has encoded up to.
+	PhaseEncode Phase = "encode"
+)
+
+// ProgressFunc receives progress updates during a conversion.
+// Updates are delivered one at a time, never concurrently.
+type ProgressFunc func(Progress)
+
+// Progress is a snapshot of a running conversion. With streaming extractors
+// the download and encode phases overlap, so both are always reported.
+type Progress struct {
+	// Phase is the stage that changed in this update.
+	Phase Phase
+	// Backend is the extractor currently in use.
+	Backend string
+	// Downloaded is the number of source bytes downloaded so far.
+	Downloaded int64
+	// Total is the size of the source in bytes, or 0 when unknown.
+	Total int64
+	// Encoded is the position in the audio encoded so far.
+	Encoded time.Duration
+	// Duration is the length of the video, or 0 when unknown.
+	Duration time.Duration
+	// Track is the track being encoded when splitting by chapter, counted
+	// from 1, and Tracks the number of tracks. Both are 0 otherwise.
+	Track, Tracks int
+}
+
+// Percent estimates how far along the conversion is, from 0 to 100.
+// It is based on the encode position when the duration is known and on
+// the downloaded bytes otherwise. It returns -1 when neither is known.
+func (p Progress) Percent() float64 {
+	switch {
+	case p.Duration > 0:
+		return min(100, 100*float64(p.Encoded)/float64(p.Duration))
+	case p.Total > 0:
+		return min(100, 100*float64(p.Downloaded)/float64(p.Total))
+	default:
+		return -1
+	}
+}
+
+// progressInterval throttles updates so callers redrawing a terminal or
+// pushing events to a browser are not flooded.
+const progressInterval = 200 * time.Millisecond
+
+// progressTracker merges the partial updates sent by extractors and
+// transcoders into full snapshots for the caller's ProgressFunc.
+type progressTracker struct {
+	mu       sync.Mutex
+	fn       ProgressFunc
+	current  Progress
+	lastSent time.Time
+}
+
+func newProgressTracker(fn ProgressFunc, backend string) *progressTracker {
+	return &progressTracker{
+		fn:      fn,
+		current: Progress{Backend: backend},
+	}
+}
+
+// update is handed to extractors and transcoders as Options.Progress.
+// Only the fields of the reported phase are taken from p.
+func (t *progressTracker) update(p Progress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.current.Phase = p.Phase
+	switch p.Phase {
+	case PhaseDownload:
+		t.current.Downloaded = p.Downloaded
+		if p.Total > 0 {
+			t.current.Total = p.Total
+		}
+	case PhaseEncode:
+		t.current.Encoded = p.Encoded
+	}
+	if p.Duration > 0 {
+		t.current.Duration = p.Duration
+	}
+
+	if time.Since(t.lastSent) < progressInterval {
+		return
+	}
+	t.lastSent = time.Now()
+	t.fn(t.current)
+}
+
+// setDuration records the video length when it is learned from metadata.
+func (t *progressTracker) setDuration(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current.Duration = d
+}
+
+// setTrack records the track SplitChapters is encoding.
+func (t *progressTracker) setTrack(track, tracks int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current.Track, t.current.Tracks = track, tracks
+}
+
+// flush sends the latest snapshot regardless of throttling.
+func (t *progressTracker) flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fn(t.current)
+}
+
+// progressReader reports the bytes read through it as download progress.
+type progressReader struct {
+	io.Reader
+	fn    ProgressFunc
+	total int64
+	read  int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += int64(n)
+	r.fn(Progress{Phase: PhaseDownload, Downloaded: r.read, Total: r.total})
+	return n, err
+}
@@ -0,0 +1,49 @@
+package mp3
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFFmpegStderr(t *testing.T) {
+	var encoded []time.Duration
+	stderr := &ffmpegStderr{progress: func(p Progress) {
+		encoded = append(encoded, p.Encoded)
+	}}
+
+	// Writes split lines anywhere, progress keys are not errors
+	for _, chunk := range []string{
+		"out_time_us=1500000\nspeed=2",
+		"0x\nprogress=continue\n[mp3 @ 0x1] Invalid data",
+		"\nout_time_us=3000000\nout_time_ms=3000000\nstream_0_0_q=-0.0\nprogress=end\ntrailing",
+	} {
+		stderr.Write([]byte(chunk))
+	}
+
+	want := []time.Duration{1500 * time.Millisecond, 3 * time.Second}
+	if !slices.Equal(encoded, want) {
+		t.Errorf("encoded = %v, want %v", encoded, want)
+	}
+	if got := stderr.String(); got != "[mp3 @ 0x1] Invalid data\ntrailing" {
+		t.Errorf("String() = %q, want only the error lines", got)
+	}
+}
+
+func TestProgressPercent(t *testing.T) {
+	tests := []struct {
+		p    Progress
+		want float64
+	}{
+		{Progress{Encoded: 30 * time.Second, Duration: time.Minute, Downloaded: 10, Total: 100}, 50},
+		{Progress{Downloaded: 25, Total: 100}, 25},
+		{Progress{Encoded: 2 * time.Minute, Duration: time.Minute}, 100},
+		{Progress{Downloaded: 25}, -1},
+	}
+
+	for _, tt := range tests {
+		if got := tt.p.Percent(); got != tt.want {
+			t.Errorf("%+v.Percent() = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package mp3
+
+// Report describes how a conversion was carried out.
+type Report struct {
+	// Backend is the name of the extractor that produced the output.
+	Backend string
+	// Bytes is the size of the encoded output written to the writer.
+	Bytes int64
+	// Options are the normalized options the output was encoded with,
+	// with automatic settings replaced by the values that were chosen.
+	Options Options
+	// Copied is set when the source audio was remuxed without
+	// re-encoding, see Options.Copy. Bitrate, sample rate and channels in
+	// Options do not apply then.
+	Copied bool
+	// Source is the audio stream that was downloaded, zero fields are
+	// unknown.
+	Source AudioFormat
+	// SourceCodec is the codec of the downloaded audio, such as "aac" or
+	// "opus", or empty when the extractor did not report it.
+	SourceCodec string
+	// Loudness is the loudness of the source measured before normalizing
+	// it, nil unless Options.Loudness is set.
+	Loudness *LoudnessStats
+}
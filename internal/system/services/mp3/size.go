@@ -0,0 +1,153 @@
+package mp3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sizeOverhead is reserved for container headers and tags when
+	// fitting the output into Options.MaxSizeBytes.
+	sizeOverhead = 64 << 10
+	// sizeMargin covers encoders overshooting the requested bitrate.
+	sizeMargin = 0.97
+	// coverOverhead is reserved for a thumbnail that is not downloaded
+	// yet, YouTube's largest JPEG thumbnails stay below it.
+	coverOverhead = 512 << 10
+)
+
+// sizeUnits maps the suffixes accepted by ParseSize to their multiplier.
+// They are checked in order, so longer suffixes come first.
+var sizeUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30},
+	{"kb", 1e3}, {"mb", 1e6}, {"gb", 1e9},
+	{"k", 1e3}, {"m", 1e6}, {"g", 1e9},
+	{"b", 1},
+}
+
+// ParseSize parses a size such as "8MB", "1.5GB", "500KiB" or "1048576"
+// into bytes. KB, MB and GB are powers of 1000, KiB, MiB and GiB powers
+// of 1024.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+
+	multiplier := 1.0
+	for _, unit := range sizeUnits {
+		if rest, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, multiplier = strings.TrimSpace(rest), unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q, use a value such as \"8MB\"", s)
+	}
+	return int64(n * multiplier), nil
+}
+
+// fitToSize replaces the bitrate in opts with the highest one whose output
+// fits in opts.MaxSizeBytes for audio of the given duration. Errors wrap
+// ErrTooLarge when even the lowest bitrate of the format does not fit.
+// AutoBitrate is kept, so the output still never goes above the source:
+// capToSize lowers it once resolveAuto has picked the source bitrate.
+func fitToSize(opts Options, duration time.Duration) (Options, error) {
+	bitrate, err := sizedBitrate(opts, duration)
+	if err != nil {
+		return opts, err
+	}
+	if opts.Bitrate != AutoBitrate {
+		opts.Bitrate = fmt.Sprintf("%dk", bitrate/1000)
+	}
+	return opts, nil
+}
+
+// capToSize lowers the bitrate resolveAuto derived from the source to the
+// highest one that fits in opts.MaxSizeBytes for audio of the given
+// duration.
+func capToSize(opts Options, duration time.Duration) Options {
+	fits, err := sizedBitrate(opts, duration)
+	if err != nil {
+		// ConvertToWriter already refused sizes that cannot fit
+		return opts
+	}
+	if current, err := parseBitrate(opts.Bitrate); err == nil && fits < current {
+		opts.Bitrate = fmt.Sprintf("%dk", fits/1000)
+	}
+	return opts
+}
+
+// sizedBitrate returns the highest bitrate in bits per second whose output
+// fits in opts.MaxSizeBytes for audio of the given duration.
+func sizedBitrate(opts Options, duration time.Duration) (int, error) {
+	if duration <= 0 {
+		return 0, fmt.Errorf("%w: the video duration is unknown", ErrTooLarge)
+	}
+
+	budget := float64(opts.MaxSizeBytes-sizeOverhead-coverReserve(opts)) * sizeMargin
+	fits := int(budget * 8 / duration.Seconds())
+
+	// MP3 bitrates depend on the sample rate, assume MPEG-1 until an
+	// automatic rate is known
+	sized := opts
+	if sized.SampleRate == Auto {
+		sized.SampleRate = 48000
+	}
+
+	bitrate := capBitrate(sized, formatLimits[opts.Format], fits)
+	if budget <= 0 || bitrate > fits {
+		return 0, fmt.Errorf("%w: %s of audio needs at least %dk to be encoded as %s, which does not fit in %s",
+			ErrTooLarge, duration.Round(time.Second), bitrate/1000, opts.Format, formatSize(opts.MaxSizeBytes))
+	}
+	return bitrate, nil
+}
+
+// clipLength returns the length of the output for audio that is total
+// long once it is cut to opts.Start and opts.End, or 0 when unknown.
+func clipLength(opts Options, total time.Duration) time.Duration {
+	end := total
+	if opts.End > 0 && (total == 0 || opts.End < total) {
+		end = opts.End
+	}
+	if end <= opts.Start {
+		return 0
+	}
+	return end - opts.Start
+}
+
+// coverReserve returns the bytes fitToSize keeps free for cover art.
+func coverReserve(opts Options) int64 {
+	switch {
+	case len(opts.Metadata.Cover) > 0:
+		return int64(len(opts.Metadata.Cover))
+	case opts.Cover:
+		return coverOverhead
+	default:
+		return 0
+	}
+}
+
+// fitsSize reports whether copying the source stream stays within
+// opts.MaxSizeBytes. Sources of unknown size are encoded instead.
+func fitsSize(opts Options, source AudioFormat) bool {
+	return opts.MaxSizeBytes == 0 || (source.Size > 0 && source.Size+sizeOverhead <= opts.MaxSizeBytes)
+}
+
+// formatSize formats bytes for error messages, such as "8.0 MB".
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1e9:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/1e9)
+	case bytes >= 1e6:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/1e6)
+	case bytes >= 1e3:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/1e3)
+	default:
+		return fmt.Sprintf("%d bytes", bytes)
+	}
+}
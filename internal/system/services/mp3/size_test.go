@@ -0,0 +1,91 @@
+package mp3
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"1048576", 1 << 20},
+		{"8MB", 8e6},
+		{"8mb", 8e6},
+		{"8 MB", 8e6},
+		{"8MiB", 8 << 20},
+		{"1.5GB", 1.5e9},
+		{"500KiB", 500 << 10},
+		{"25M", 25e6},
+		{"100b", 100},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if err != nil {
+			t.Errorf("ParseSize(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSizeErrors(t *testing.T) {
+	for _, in := range []string{"", "MB", "big", "-8MB", "0", "8TB"} {
+		if got, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+func TestFitToSize(t *testing.T) {
+	opts := normalizeOptions(&Options{Channels: 2, SampleRate: 44100, MaxSizeBytes: 8e6})
+
+	fitted, err := fitToSize(opts, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("fitToSize error: %v", err)
+	}
+	if fitted.Bitrate != "192k" {
+		t.Errorf("Bitrate = %s, want 192k", fitted.Bitrate)
+	}
+
+	if _, err := fitToSize(opts, 10*time.Hour); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("fitToSize for 10h error = %v, want ErrTooLarge", err)
+	}
+	if _, err := fitToSize(opts, 0); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("fitToSize for an unknown duration error = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestFitToSizeAutoBitrate(t *testing.T) {
+	opts := normalizeOptions(&Options{Bitrate: AutoBitrate, SampleRate: Auto, Channels: Auto, MaxSizeBytes: 8e6})
+
+	fitted, err := fitToSize(opts, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("fitToSize error: %v", err)
+	}
+	if fitted.Bitrate != AutoBitrate {
+		t.Fatalf("Bitrate = %s, want it left automatic", fitted.Bitrate)
+	}
+
+	s := New()
+	tests := []struct {
+		source int
+		want   string
+	}{
+		// The source is below the limit, it is not raised
+		{48000, "48k"},
+		{256000, "192k"},
+	}
+	for _, tt := range tests {
+		source := AudioFormat{Bitrate: tt.source, SampleRate: 48000, Channels: 2}
+		got := s.prepare(t.Context(), fitted, source, nil, 5*time.Minute, "")
+		if got.Bitrate != tt.want {
+			t.Errorf("%dk source: Bitrate = %s, want %s", tt.source/1000, got.Bitrate, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package mp3
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SourcePreference ranks the audio streams YouTube offers for a video.
+type SourcePreference string
+
+const (
+	// SourceBest picks the stream with the highest bitrate.
+	SourceBest SourcePreference = "best"
+	// SourceSmallest picks the smallest stream, which downloads fastest.
+	SourceSmallest SourcePreference = "smallest"
+	// SourceClosest picks the stream whose bitrate is closest to the
+	// target, so little quality is downloaded only to be thrown away.
+	SourceClosest SourcePreference = "closest"
+)
+
+// ParseSourcePreference returns the SourcePreference for a name such as
+// "smallest".
+func ParseSourcePreference(name string) (SourcePreference, error) {
+	pref := SourcePreference(strings.ToLower(strings.TrimSpace(name)))
+	switch pref {
+	case SourceBest, SourceSmallest, SourceClosest:
+		return pref, nil
+	default:
+		return "", fmt.Errorf("unknown source preference %q, use best, smallest or closest", name)
+	}
+}
+
+// SourcePolicy chooses which audio stream of a video is downloaded. Every
+// extractor ranks its candidates with the same policy. Codec and Language
+// are preferences: when no stream matches them the other streams are
+// still used.
+type SourcePolicy struct {
+	// Prefer is the ranking of the remaining streams (default: SourceBest)
+	Prefer SourcePreference
+	// TargetBitrate is the bitrate SourceClosest aims for, such as "128k"
+	// (default: the output Bitrate, or the highest bitrate when the output
+	// Bitrate is AutoBitrate)
+	TargetBitrate string
+	// Codec prefers streams in this codec, such as "opus" or "aac"
+	Codec string
+	// Language prefers audio tracks in this language, such as "en" or
+	// "es-419". A bare language also matches its regional variants.
+	Language string
+}
+
+// CandidateFormats returns the audio formats in the order the extractors
+// consider them for opts, the first one is downloaded. If opts is nil,
+// DefaultOptions() will be used.
+func (v *VideoInfo) CandidateFormats(opts *Options) []AudioFormat {
+	resolved := normalizeOptions(opts)
+
+	ranked := make([]AudioFormat, 0, len(v.AudioFormats))
+	for _, i := range rankAudioFormats(v.AudioFormats, v.Duration, resolved) {
+		ranked = append(ranked, v.AudioFormats[i])
+	}
+	return ranked
+}
+
+// rankAudioFormats returns the indexes of formats from the most to the
+// least preferred for a video that is duration long, or 0 when unknown.
+// Streams that can be copied within opts.MaxSizeBytes when opts.Copy is
+// set come first, then streams in the preferred language and codec, then
+// the SourcePreference decides.
+func rankAudioFormats(formats []AudioFormat, duration time.Duration, opts Options) []int {
+	policy := opts.Source
+	target := 0
+	if policy.Prefer == SourceClosest {
+		var err error
+		if target, err = parseBitrate(cmp.Or(policy.TargetBitrate, opts.Bitrate)); err != nil {
+			// There is no target when the bitrate is automatic
+			policy.Prefer = SourceBest
+		}
+	}
+
+	// preferred sorts true before false
+	preferred := func(a, b bool) int {
+		switch {
+		case a == b:
+			return 0
+		case a:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	order := make([]int, len(formats))
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortStableFunc(order, func(i, j int) int {
+		a, b := formats[i], formats[j]
+
+		if opts.Copy {
+			copyA := opts.Format.canCopy(normalizeCodec(a.Codec)) && fitsSize(opts, a)
+			copyB := opts.Format.canCopy(normalizeCodec(b.Codec)) && fitsSize(opts, b)
+			if c := preferred(copyA, copyB); c != 0 {
+				return c
+			}
+		}
+		if policy.Language != "" {
+			if c := preferred(matchesLanguage(a.Language, policy.Language), matchesLanguage(b.Language, policy.Language)); c != 0 {
+				return c
+			}
+		}
+		if policy.Codec != "" {
+			codec := normalizeCodec(policy.Codec)
+			if c := preferred(normalizeCodec(a.Codec) == codec, normalizeCodec(b.Codec) == codec); c != 0 {
+				return c
+			}
+		}
+
+		switch policy.Prefer {
+		case SourceSmallest:
+			sizeA, sizeB := estimatedSize(a, duration), estimatedSize(b, duration)
+			// Streams whose size cannot be estimated go last
+			if c := preferred(sizeA > 0, sizeB > 0); c != 0 {
+				return c
+			}
+			return cmp.Or(cmp.Compare(sizeA, sizeB), cmp.Compare(a.Bitrate, b.Bitrate))
+		case SourceClosest:
+			return cmp.Compare(distance(a.Bitrate, target), distance(b.Bitrate, target))
+		default:
+			return cmp.Compare(b.Bitrate, a.Bitrate)
+		}
+	})
+
+	return order
+}
+
+// estimatedSize returns the size of the stream in bytes, estimated from
+// its bitrate and the video duration when the extractor does not know it.
+// It returns 0 when neither is known.
+func estimatedSize(f AudioFormat, duration time.Duration) int64 {
+	if f.Size > 0 {
+		return f.Size
+	}
+	return int64(float64(f.Bitrate) / 8 * duration.Seconds())
+}
+
+// matchesLanguage reports whether the track language matches the wanted
+// one: "en" matches "en" and "en-US", "en-US" only matches itself.
+func matchesLanguage(track, want string) bool {
+	track, want = strings.ToLower(track), strings.ToLower(want)
+	return track == want || strings.HasPrefix(track, want+"-")
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
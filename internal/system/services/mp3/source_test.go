@@ -0,0 +1,60 @@
+package mp3
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRankAudioFormats(t *testing.T) {
+	formats := []AudioFormat{
+		{ID: "opus-160", Codec: "opus", Bitrate: 160000, Language: "en"},
+		{ID: "aac-128", Codec: "mp4a.40.2", Bitrate: 128000, Size: 3e6, Language: "en"},
+		{ID: "opus-70", Codec: "opus", Bitrate: 70000, Language: "es"},
+		{ID: "unknown", Codec: "opus"},
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"best", Options{Source: SourcePolicy{Prefer: SourceBest}}, []string{"opus-160", "aac-128", "opus-70", "unknown"}},
+		// opus-70 is about 2.1 MB and opus-160 about 4.8 MB over 4 minutes
+		{"smallest", Options{Source: SourcePolicy{Prefer: SourceSmallest}}, []string{"opus-70", "aac-128", "opus-160", "unknown"}},
+		{"closest", Options{Source: SourcePolicy{Prefer: SourceClosest, TargetBitrate: "96k"}}, []string{"opus-70", "aac-128", "opus-160", "unknown"}},
+		{"closest to automatic", Options{Bitrate: AutoBitrate, Source: SourcePolicy{Prefer: SourceClosest}}, []string{"opus-160", "aac-128", "opus-70", "unknown"}},
+		{"codec", Options{Source: SourcePolicy{Prefer: SourceBest, Codec: "aac"}}, []string{"aac-128", "opus-160", "opus-70", "unknown"}},
+		{"language", Options{Source: SourcePolicy{Prefer: SourceBest, Language: "es"}}, []string{"opus-70", "opus-160", "aac-128", "unknown"}},
+		{"copy", Options{Format: FormatM4A, Copy: true, Source: SourcePolicy{Prefer: SourceBest}}, []string{"aac-128", "opus-160", "opus-70", "unknown"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, i := range rankAudioFormats(formats, 4*time.Minute, tt.opts) {
+			got = append(got, formats[i].ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: ranking = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesLanguage(t *testing.T) {
+	tests := []struct {
+		track, want string
+		match       bool
+	}{
+		{"en", "en", true},
+		{"en-US", "en", true},
+		{"EN-us", "en-US", true},
+		{"en", "en-US", false},
+		{"eng", "en", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesLanguage(tt.track, tt.want); got != tt.match {
+			t.Errorf("matchesLanguage(%q, %q) = %v, want %v", tt.track, tt.want, got, tt.match)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package mp3
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Track is one chapter written by SplitChapters.
+type Track struct {
+	// Number counts the tracks from 1.
+	Number int
+	// Total is the number of tracks.
+	Total int
+	// Chapter is the part of the video in the track, with Start and End
+	// relative to the start of the output.
+	Chapter Chapter
+	// Filename is a file name for the track, such as "03 - Intro.mp3".
+	Filename string
+}
+
+// SplitChapters downloads a video once and encodes every chapter into a
+// file of its own. Tracks are tagged with the chapter title, the track
+// number and the video title as album, unless opts.Metadata sets one.
+//
+// create is called for every track in order and returns the writer the
+// track is encoded into; SplitChapters closes it once the track is done.
+// The chapters come from opts.Metadata.Chapters or the video, errors wrap
+// ErrNoChapters when there are none. Options.MaxSizeBytes is not
+// supported. The reports are in track order, including those written
+// before an error.
+func (s *Service) SplitChapters(ctx context.Context, videoURL string, opts *Options, create func(Track) (io.WriteCloser, error)) ([]*Report, error) {
+	ref, err := s.policy.ParseVideoURL(videoURL)
+	if err != nil {
+		return nil, err
+	}
+	cleanURL := ref.URL()
+
+	resolved := withURLStart(normalizeOptions(opts), ref)
+	if !resolved.Format.Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, resolved.Format)
+	}
+	if resolved.MaxSizeBytes > 0 {
+		return nil, ValidationErrors{{Field: "MaxSizeBytes", Message: "cannot be combined with splitting by chapter"}}
+	}
+	if err := resolved.Validate(); err != nil {
+		return nil, err
+	}
+
+	source, err := s.downloadSource(ctx, cleanURL, resolved)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(source.path)
+
+	base := s.prepare(ctx, resolved, source.format, source.info, source.duration, cleanURL)
+	chapters := base.Metadata.Chapters
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoChapters, cleanURL)
+	}
+	if source.tracker != nil {
+		source.tracker.setDuration(clipLength(base, source.duration))
+	}
+
+	reports := make([]*Report, 0, len(chapters))
+	for i, chapter := range chapters {
+		track := Track{Number: i + 1, Total: len(chapters), Chapter: chapter}
+		track.Filename = fmt.Sprintf("%02d - %s%s", track.Number, SanitizeFilename(trackTitle(track)), base.Format.Extension())
+
+		s.logger.Debug("encoding track", "url", cleanURL, "track", track.Number, "title", chapter.Title)
+		report, err := s.writeTrack(ctx, source, base, track, create)
+		if err != nil {
+			return reports, fmt.Errorf("track %d %q: %w", track.Number, chapter.Title, err)
+		}
+		report.Options.Progress = resolved.Progress
+		reports = append(reports, report)
+	}
+
+	if source.tracker != nil {
+		source.tracker.flush()
+	}
+	return reports, nil
+}
+
+// writeTrack encodes the chapter of track from the downloaded source.
+func (s *Service) writeTrack(ctx context.Context, source *sourceFile, base Options, track Track, create func(Track) (io.WriteCloser, error)) (*Report, error) {
+	opts := base
+	// Chapters are relative to base.Start, the source file is not
+	opts.Start = base.Start + track.Chapter.Start
+	if track.Chapter.End > 0 {
+		opts.End = base.Start + track.Chapter.End
+	}
+	opts.Metadata.Title = trackTitle(track)
+	opts.Metadata.Album = cmp.Or(base.Metadata.Album, base.Metadata.Title)
+	opts.Metadata.Track, opts.Metadata.TrackTotal = track.Number, track.Total
+	opts.Metadata.Chapters = []Chapter{}
+
+	if tracker := source.tracker; tracker != nil {
+		tracker.setTrack(track.Number, track.Total)
+		// Encode progress counts through the whole video, not per track
+		opts.Progress = func(p Progress) {
+			if p.Phase == PhaseEncode {
+				p.Encoded += track.Chapter.Start
+			}
+			tracker.update(p)
+		}
+	}
+
+	src, err := os.Open(source.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open downloaded audio: %w", err)
+	}
+	defer src.Close()
+
+	w, err := create(track)
+	if err != nil {
+		return nil, err
+	}
+	out := &countingWriter{w: w}
+
+	encodeCtx, cancel := withTimeout(ctx, s.timeouts.Encode)
+	defer cancel()
+
+	report, err := s.encode(encodeCtx, src, out, opts, source.format, source.offset, source.url)
+	if closeErr := w.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report.Backend, report.Bytes = source.backend, out.written
+	return report, nil
+}
+
+// trackTitle is the chapter title, or "Track n" for untitled chapters.
+func trackTitle(track Track) string {
+	return cmp.Or(track.Chapter.Title, fmt.Sprintf("Track %d", track.Number))
+}
+
+// sourceFile is source audio downloaded to a temporary file, so it can be
+// encoded more than once.
+type sourceFile struct {
+	path     string
+	url      string
+	backend  string
+	format   AudioFormat
+	duration time.Duration
+	// offset is the position in the video the file begins at.
+	offset time.Duration
+	info   *VideoInfo
+	// tracker reports progress to Options.Progress, nil when unset.
+	tracker *progressTracker
+}
+
+// downloadSource downloads the source audio with the first extractor
+// that succeeds. The caller removes the file.
+func (s *Service) downloadSource(ctx context.Context, videoURL string, opts Options) (*sourceFile, error) {
+	if len(s.extractors) == 0 {
+		return nil, ErrNoExtractors
+	}
+
+	var errs BackendErrors
+	for _, extractor := range s.extractors {
+		s.logger.Debug("downloading video", "url", videoURL, "backend", extractor.Name())
+		source, err := s.downloadWith(ctx, extractor, videoURL, opts)
+		if err == nil {
+			return source, nil
+		}
+
+		s.logger.Warn("download failed", "url", videoURL, "backend", extractor.Name(), "error", err)
+		errs = append(errs, &BackendError{Backend: extractor.Name(), Err: err})
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("download cancelled: %w", ctx.Err())
+		}
+	}
+
+	return nil, errs
+}
+
+func (s *Service) downloadWith(ctx context.Context, extractor Extractor, videoURL string, opts Options) (*sourceFile, error) {
+	downloadCtx, cancel := withTimeout(ctx, s.timeouts.Download)
+	defer cancel()
+
+	var tracker *progressTracker
+	if opts.Progress != nil {
+		tracker = newProgressTracker(opts.Progress, extractor.Name())
+		opts.Progress = tracker.update
+	}
+
+	stream, err := extractor.Open(downloadCtx, videoURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	file, err := os.CreateTemp(s.tempDir, "gomp3-source-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	_, err = io.Copy(file, stream)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// Closing the stream reports download errors, such as yt-dlp failing
+		err = stream.Close()
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	source := &sourceFile{
+		path:     file.Name(),
+		url:      videoURL,
+		backend:  extractor.Name(),
+		format:   stream.Format,
+		duration: stream.Duration,
+		offset:   stream.Start,
+		info:     s.streamInfo(downloadCtx, extractor, videoURL, stream, opts.Info),
+		tracker:  tracker,
+	}
+	if source.duration == 0 && source.info != nil {
+		source.duration = source.info.Duration
+	}
+	return source, nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w       io.Writer
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.written += int64(n)
+	return n, err
+}
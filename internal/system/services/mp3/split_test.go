@@ -0,0 +1,104 @@
+package mp3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+// bufferCloser keeps a track in memory.
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestSplitChapters(t *testing.T) {
+	extractor := &fakeExtractor{name: "fake", audio: "audio"}
+	svc := New(WithExtractors(extractor), WithTranscoder(&fakeTranscoder{}))
+
+	opts := &Options{Metadata: Metadata{Chapters: []Chapter{
+		{Title: "Intro", Start: 0},
+		{Title: "", Start: time.Minute},
+		{Title: "Outro/Credits", Start: 2 * time.Minute, End: 3 * time.Minute},
+	}}}
+
+	var names []string
+	var files []*bufferCloser
+	reports, err := svc.SplitChapters(t.Context(), testVideoURL, opts, func(track Track) (io.WriteCloser, error) {
+		names = append(names, track.Filename)
+		files = append(files, &bufferCloser{})
+		return files[len(files)-1], nil
+	})
+	if err != nil {
+		t.Fatalf("SplitChapters error: %v", err)
+	}
+	if extractor.opened != 1 {
+		t.Errorf("opened the stream %d times, want once", extractor.opened)
+	}
+
+	wantNames := []string{"01 - Intro.mp3", "02 - Track 2.mp3", "03 - Outro_Credits.mp3"}
+	if !slices.Equal(names, wantNames) {
+		t.Errorf("filenames = %v, want %v", names, wantNames)
+	}
+	for i, f := range files {
+		if !f.closed || f.String() != "audio" {
+			t.Errorf("track %d: closed %v, %q written", i+1, f.closed, f.String())
+		}
+	}
+
+	second := reports[1].Options
+	if second.Start != time.Minute || second.End != 2*time.Minute {
+		t.Errorf("track 2 clip = %s-%s, want 1m0s-2m0s", second.Start, second.End)
+	}
+	if m := second.Metadata; m.Title != "Track 2" || m.Album != "Test video" || m.Track != 2 || m.TrackTotal != 3 || len(m.Chapters) != 0 {
+		t.Errorf("track 2 tags = %+v", m)
+	}
+}
+
+func TestSplitChaptersNoChapters(t *testing.T) {
+	svc := New(WithExtractors(&fakeExtractor{name: "fake", audio: "audio"}), WithTranscoder(&fakeTranscoder{}))
+
+	_, err := svc.SplitChapters(t.Context(), testVideoURL, nil, func(Track) (io.WriteCloser, error) {
+		t.Fatal("create called without chapters")
+		return nil, nil
+	})
+	if !errors.Is(err, ErrNoChapters) {
+		t.Errorf("error = %v, want ErrNoChapters", err)
+	}
+}
+
+func TestTrimChapters(t *testing.T) {
+	chapters := []Chapter{
+		{Title: "A", Start: 0, End: time.Minute},
+		{Title: "B", Start: time.Minute, End: 2 * time.Minute},
+		{Title: "C", Start: 2 * time.Minute},
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Duration
+		want       []Chapter
+	}{
+		{"whole video", 0, 0, chapters},
+		{"middle", 90 * time.Second, 150 * time.Second, []Chapter{
+			{Title: "B", Start: 0, End: 30 * time.Second},
+			{Title: "C", Start: 30 * time.Second, End: time.Minute},
+		}},
+		{"open end", 2 * time.Minute, 0, []Chapter{{Title: "C", Start: 0}}},
+		{"chapter boundaries", time.Minute, 2 * time.Minute, []Chapter{{Title: "B", Start: 0, End: time.Minute}}},
+	}
+
+	for _, tt := range tests {
+		if got := trimChapters(chapters, tt.start, tt.end); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: trimChapters = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package mp3
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChapterSource chooses where the chapters of the output come from.
+type ChapterSource string
+
+const (
+	// ChaptersOfficial uses the chapters YouTube shows for the video.
+	ChaptersOfficial ChapterSource = "official"
+	// ChaptersDescription parses a timestamped tracklist in the video
+	// description, see ParseTracklist.
+	ChaptersDescription ChapterSource = "description"
+	// ChaptersNone writes no chapters.
+	ChaptersNone ChapterSource = "none"
+)
+
+// ParseChapterSource returns the ChapterSource for a name such as
+// "description".
+func ParseChapterSource(name string) (ChapterSource, error) {
+	source := ChapterSource(strings.ToLower(strings.TrimSpace(name)))
+	switch source {
+	case ChaptersOfficial, ChaptersDescription, ChaptersNone:
+		return source, nil
+	default:
+		return "", fmt.Errorf("unknown chapter source %q, use official, description or none", name)
+	}
+}
+
+// ChaptersFrom returns the chapters of the video from source. Tracklist
+// entries past the end of the video are left out.
+func (v *VideoInfo) ChaptersFrom(source ChapterSource) []Chapter {
+	switch source {
+	case ChaptersNone:
+		return nil
+	case ChaptersDescription:
+		var chapters []Chapter
+		for _, c := range ParseTracklist(v.Description) {
+			if v.Duration > 0 && c.Start >= v.Duration {
+				break
+			}
+			chapters = append(chapters, c)
+		}
+		return chapters
+	default:
+		return v.Chapters
+	}
+}
+
+// minTracklist is the number of timestamps a tracklist needs, fewer are
+// more likely to point at moments of the video than to list its parts.
+const minTracklist = 3
+
+var (
+	// timestampPattern matches a timestamp such as 3:12, 03:12 or 1:02:03,
+	// with the brackets around it if there are any.
+	timestampPattern = regexp.MustCompile(`[\[(]?\b(?:(\d{1,2}):)?(\d{1,3}):(\d{2})\b[\])]?`)
+	// rangePattern matches the text between the two timestamps of a range
+	// such as "00:00 - 03:12".
+	rangePattern = regexp.MustCompile(`^\s*(?:-|–|—|~|to)\s*$`)
+	// numberingPattern matches list numbering such as "1." or "01)".
+	numberingPattern = regexp.MustCompile(`^\d{1,3}[.)]\s+`)
+)
+
+// titleSeparators are trimmed from both ends of tracklist titles.
+const titleSeparators = " \t-–—|:•·*,;/~"
+
+// ParseTracklist returns the chapters listed in a video description as
+// timestamped lines, such as "00:00 Intro", "1. Song A - 3:12",
+// "[1:02:03] Outro" or "00:00 - 03:12 Song B". Several entries on one
+// line, like "00:00 Intro / 03:12 Song B", are split too. Ranges set the
+// chapter End, the other chapters last until the next one.
+//
+// The longest run of at least three timestamps in ascending order is the
+// tracklist, so timestamps mentioned elsewhere in the description are
+// ignored. It returns nil when the description has no tracklist.
+func ParseTracklist(description string) []Chapter {
+	var best, run []Chapter
+	for _, line := range strings.Split(description, "\n") {
+		for _, c := range parseTracklistLine(line) {
+			if len(run) > 0 && c.Start <= run[len(run)-1].Start {
+				run = nil
+			}
+			run = append(run, c)
+			if len(run) > len(best) {
+				best = run
+			}
+		}
+	}
+
+	if len(best) < minTracklist {
+		return nil
+	}
+	return best
+}
+
+// tracklistEntry is a timestamp or range found in a line, with the byte
+// offsets of the text it spans.
+type tracklistEntry struct {
+	start, end time.Duration
+	from, to   int
+}
+
+// parseTracklistLine returns the chapters of a single description line.
+func parseTracklistLine(line string) []Chapter {
+	var entries []tracklistEntry
+	for _, m := range timestampPattern.FindAllStringSubmatchIndex(line, -1) {
+		d, ok := parseTrackTimestamp(line, m)
+		if !ok {
+			continue
+		}
+
+		// The second timestamp of a range ends the previous entry
+		if n := len(entries); n > 0 && entries[n-1].end == 0 && d > entries[n-1].start && rangePattern.MatchString(line[entries[n-1].to:m[0]]) {
+			entries[n-1].end, entries[n-1].to = d, m[1]
+			continue
+		}
+		entries = append(entries, tracklistEntry{start: d, from: m[0], to: m[1]})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	chapters := make([]Chapter, len(entries))
+	for i, e := range entries {
+		chapters[i] = Chapter{Start: e.start, End: e.end}
+	}
+
+	// A single entry takes the text after it, or before it when the
+	// timestamp ends the line. With several entries the titles follow
+	// the timestamps unless the line starts with a title.
+	if len(entries) == 1 {
+		e := entries[0]
+		chapters[0].Title = cleanTrackTitle(line[e.to:])
+		if chapters[0].Title == "" {
+			chapters[0].Title = cleanTrackTitle(line[:e.from])
+		}
+		return chapters
+	}
+
+	titlesFirst := cleanTrackTitle(line[:entries[0].from]) != ""
+	for i, e := range entries {
+		switch {
+		case titlesFirst && i == 0:
+			chapters[i].Title = cleanTrackTitle(line[:e.from])
+		case titlesFirst:
+			chapters[i].Title = cleanTrackTitle(line[entries[i-1].to:e.from])
+		case i+1 < len(entries):
+			chapters[i].Title = cleanTrackTitle(line[e.to:entries[i+1].from])
+		default:
+			chapters[i].Title = cleanTrackTitle(line[e.to:])
+		}
+	}
+	return chapters
+}
+
+// parseTrackTimestamp converts the timestampPattern match m in line to a
+// duration. Seconds past 59 are rejected, as are minutes past 59 when
+// the timestamp has hours.
+func parseTrackTimestamp(line string, m []int) (time.Duration, bool) {
+	var hours int
+	if m[2] >= 0 {
+		hours, _ = strconv.Atoi(line[m[2]:m[3]])
+	}
+	minutes, _ := strconv.Atoi(line[m[4]:m[5]])
+	seconds, _ := strconv.Atoi(line[m[6]:m[7]])
+
+	if seconds > 59 || (m[2] >= 0 && minutes > 59) {
+		return 0, false
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second, true
+}
+
+// cleanTrackTitle trims list numbering and separators from s.
+func cleanTrackTitle(s string) string {
+	s = strings.Trim(s, titleSeparators)
+	s = numberingPattern.ReplaceAllString(s, "")
+	return strings.Trim(s, titleSeparators)
+}
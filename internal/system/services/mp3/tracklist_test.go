@@ -0,0 +1,114 @@
+package mp3
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTracklist(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		want        []Chapter
+	}{
+		{
+			name: "timestamps first",
+			description: `Thanks for watching!
+
+00:00 Intro
+03:12 Song A
+1:02:03 Outro`,
+			want: []Chapter{
+				{Title: "Intro", Start: 0},
+				{Title: "Song A", Start: 3*time.Minute + 12*time.Second},
+				{Title: "Outro", Start: time.Hour + 2*time.Minute + 3*time.Second},
+			},
+		},
+		{
+			name: "numbered with trailing timestamps",
+			description: `1. Song A - 0:00
+2. Song B - 3:12
+3. Song C - 7:45`,
+			want: []Chapter{
+				{Title: "Song A", Start: 0},
+				{Title: "Song B", Start: 3*time.Minute + 12*time.Second},
+				{Title: "Song C", Start: 7*time.Minute + 45*time.Second},
+			},
+		},
+		{
+			name: "brackets and ranges",
+			description: `[00:00 - 03:12] Song A
+[03:12 - 05:00] Song B
+[05:00] Song C`,
+			want: []Chapter{
+				{Title: "Song A", Start: 0, End: 3*time.Minute + 12*time.Second},
+				{Title: "Song B", Start: 3*time.Minute + 12*time.Second, End: 5 * time.Minute},
+				{Title: "Song C", Start: 5 * time.Minute},
+			},
+		},
+		{
+			name:        "several entries on one line",
+			description: "00:00 Intro / 03:12 Song A / 06:40 Song B",
+			want: []Chapter{
+				{Title: "Intro", Start: 0},
+				{Title: "Song A", Start: 3*time.Minute + 12*time.Second},
+				{Title: "Song B", Start: 6*time.Minute + 40*time.Second},
+			},
+		},
+		{
+			name: "stray timestamps are ignored",
+			description: `The drop at 12:30 is the best part.
+
+00:00 Intro
+01:00 Song A
+02:00 Song B
+
+Recorded live, see 00:45 for the crowd.`,
+			want: []Chapter{
+				{Title: "Intro", Start: 0},
+				{Title: "Song A", Start: time.Minute},
+				{Title: "Song B", Start: 2 * time.Minute},
+			},
+		},
+		{
+			name: "too few timestamps",
+			description: `00:00 Intro
+03:12 Song A`,
+		},
+		{
+			name:        "no timestamps",
+			description: "Just a video.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseTracklist(tt.description)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseTracklist() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChaptersFrom(t *testing.T) {
+	info := &VideoInfo{
+		Duration: 5 * time.Minute,
+		Chapters: []Chapter{{Title: "Official", Start: 0}},
+		Description: `00:00 Intro
+01:00 Song A
+02:00 Song B
+09:00 Past the end`,
+	}
+
+	if got := info.ChaptersFrom(ChaptersOfficial); len(got) != 1 || got[0].Title != "Official" {
+		t.Errorf("official chapters = %+v", got)
+	}
+	if got := info.ChaptersFrom(ChaptersNone); got != nil {
+		t.Errorf("no chapters = %+v, want nil", got)
+	}
+	if got := info.ChaptersFrom(ChaptersDescription); len(got) != 3 {
+		t.Errorf("description chapters = %+v, want the three within the video", got)
+	}
+}
@@ -0,0 +1,429 @@
+package mp3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transcoder encodes an audio source into the format described by Options.
+// The Service uses FFmpeg by default; WithTranscoder swaps in another
+// implementation, such as a fake for offline tests or a wrapper that
+// limits resources.
+type Transcoder interface {
+	// Transcode reads the source audio from src and writes the encoded
+	// audio to w. The options are already normalized. Transcoders report
+	// the encode position to opts.Progress when it is set.
+	Transcode(ctx context.Context, src io.Reader, w io.Writer, opts Options) error
+}
+
+// Remuxer is implemented by transcoders that can move the source audio
+// into the output container without re-encoding it. The Service uses it
+// for Options.Copy when the source codec fits the output format, and
+// falls back to Transcode otherwise.
+type Remuxer interface {
+	Remux(ctx context.Context, src io.Reader, w io.Writer, opts Options) error
+}
+
+// Normalizer is implemented by transcoders that can normalize loudness.
+// The Service uses it for Options.Loudness, conversions with a loudness
+// target fail when the transcoder does not implement it.
+type Normalizer interface {
+	// Normalize measures the loudness of src, then encodes it like
+	// Transcode with the gain that reaches opts.Loudness. It returns the
+	// measurement.
+	Normalize(ctx context.Context, src io.Reader, w io.Writer, opts Options) (*LoudnessStats, error)
+}
+
+// FFmpeg transcodes audio with the ffmpeg command line tool.
+type FFmpeg struct {
+	// Path is the ffmpeg executable (default: "ffmpeg" looked up on PATH).
+	Path string
+	// TempDir is where outputs that need to be seekable are encoded
+	// before being copied to the writer (default: the system temp dir).
+	TempDir string
+}
+
+// Transcode pipes src through ffmpeg and writes the result to w.
+//
+// VBR MP3 is encoded into a temporary file first: ffmpeg only writes the
+// Xing/LAME header, which holds the frame count and seek table players
+// need for VBR files, when it can seek back to the start of the output.
+// Other outputs are piped to w as they are encoded.
+func (f *FFmpeg) Transcode(ctx context.Context, src io.Reader, w io.Writer, opts Options) error {
+	return f.convert(ctx, src, w, opts, false, "")
+}
+
+// Remux copies the audio in src into the container of opts.Format with
+// ffmpeg -c:a copy.
+func (f *FFmpeg) Remux(ctx context.Context, src io.Reader, w io.Writer, opts Options) error {
+	return f.convert(ctx, src, w, opts, true, "")
+}
+
+// Normalize runs ffmpeg's loudnorm filter twice. The first pass measures
+// src while it is saved to a temporary file, the second one encodes the
+// file with the measured gain. Silent sources are encoded as they are.
+func (f *FFmpeg) Normalize(ctx context.Context, src io.Reader, w io.Writer, opts Options) (*LoudnessStats, error) {
+	source, err := os.CreateTemp(f.TempDir, "gomp3-loudnorm-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(source.Name())
+	defer source.Close()
+
+	stats, audible, err := f.measure(ctx, io.TeeReader(src, source), opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := source.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind temp file: %w", err)
+	}
+
+	var filter string
+	if audible {
+		filter = loudnormFilter(opts.Loudness, stats)
+	}
+	if err := f.convert(ctx, source, w, opts, false, filter); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// measure runs the first loudnorm pass over the part of src between
+// opts.Start and opts.End.
+func (f *FFmpeg) measure(ctx context.Context, src io.Reader, opts Options) (*LoudnessStats, bool, error) {
+	// loudnorm prints the measurement at the info log level
+	args := []string{"-hide_banner", "-nostats", "-i", "pipe:0", "-vn"}
+	args = append(args, clipArgs(opts)...)
+	args = append(args, "-af", loudnormFilter(opts.Loudness, nil), "-f", "null", "-")
+
+	log, err := f.execute(ctx, src, nil, args, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	return parseLoudnorm(log)
+}
+
+func (f *FFmpeg) convert(ctx context.Context, src io.Reader, w io.Writer, opts Options, copyAudio bool, filter string) error {
+	in, err := f.writeInputs(opts)
+	if err != nil {
+		return err
+	}
+	defer in.remove()
+
+	// MP4 keeps the cover and chapters in the moov atom, which fragmented
+	// output sends before the picture and the chapter track are written
+	seekable := opts.Format.seekable(opts.Mode) || (opts.Format == FormatM4A && (in.cover != "" || in.chapters))
+	if !seekable {
+		return f.run(ctx, src, w, f.args(opts, "-", copyAudio, filter, in), opts.Progress)
+	}
+
+	tmp, err := os.CreateTemp(f.TempDir, "gomp3-*"+opts.Format.Extension())
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// The file: prefix keeps ffmpeg from reading the path as a protocol
+	if err := f.run(ctx, src, nil, f.args(opts, "file:"+tmp.Name(), copyAudio, filter, in), opts.Progress); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, tmp); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// run executes ffmpeg with args, writing its standard output to w.
+func (f *FFmpeg) run(ctx context.Context, src io.Reader, w io.Writer, args []string, progress ProgressFunc) error {
+	_, err := f.execute(ctx, src, w, args, progress)
+	return err
+}
+
+// execute runs ffmpeg like run and returns its log output, without the
+// progress lines.
+func (f *FFmpeg) execute(ctx context.Context, src io.Reader, w io.Writer, args []string, progress ProgressFunc) (string, error) {
+	path := f.Path
+	if path == "" {
+		path = "ffmpeg"
+	}
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = src
+	cmd.Stdout = w
+
+	stderr := &ffmpegStderr{progress: progress}
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("%w: %w", ErrBackendMissing, err)
+		}
+		return "", &FFmpegError{Stderr: strings.TrimSpace(stderr.String()), Err: err}
+	}
+
+	return stderr.String(), nil
+}
+
+// ffmpegInputs are temporary files ffmpeg reads next to the audio.
+type ffmpegInputs struct {
+	// cover is an image embedded as an attached picture
+	cover string
+	// metadata is an FFMETADATA file with tags too long for the command
+	// line and the chapters
+	metadata string
+	// chapters is set when the metadata file has chapters
+	chapters bool
+}
+
+// writeInputs writes the files for the cover art and chapters in
+// opts.Metadata.
+func (f *FFmpeg) writeInputs(opts Options) (in ffmpegInputs, err error) {
+	defer func() {
+		if err != nil {
+			in.remove()
+		}
+	}()
+
+	spec := formatSpecs[opts.Format]
+	var metadata strings.Builder
+
+	if len(opts.Metadata.Cover) > 0 {
+		switch spec.cover {
+		case coverAttached:
+			config, err := coverConfig(opts.Metadata.Cover)
+			if err != nil {
+				return in, err
+			}
+			// The extension tells ffmpeg which image demuxer to use
+			ext := "." + strings.TrimPrefix(config.mimeType, "image/")
+			if in.cover, err = f.writeTemp("gomp3-cover-*"+ext, opts.Metadata.Cover); err != nil {
+				return in, err
+			}
+		case coverVorbis:
+			// A picture easily exceeds the length limit of a single argument
+			picture, err := vorbisPicture(opts.Metadata.Cover)
+			if err != nil {
+				return in, err
+			}
+			metadata.WriteString("METADATA_BLOCK_PICTURE=" + escapeMetadata(picture) + "\n")
+		}
+	}
+
+	if opts.Format.SupportsChapters() && len(opts.Metadata.Chapters) > 0 {
+		writeChapters(&metadata, opts.Metadata.Chapters)
+		in.chapters = true
+	}
+
+	if metadata.Len() > 0 {
+		if in.metadata, err = f.writeTemp("gomp3-metadata-*.txt", []byte(";FFMETADATA1\n"+metadata.String())); err != nil {
+			return in, err
+		}
+	}
+	return in, nil
+}
+
+func (f *FFmpeg) writeTemp(pattern string, data []byte) (string, error) {
+	file, err := os.CreateTemp(f.TempDir, pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// remove deletes the temporary files.
+func (in ffmpegInputs) remove() {
+	for _, path := range []string{in.cover, in.metadata} {
+		if path != "" {
+			os.Remove(path)
+		}
+	}
+}
+
+// args builds the ffmpeg command line writing to output, which is "-"
+// for the standard output or a file. filter is an audio filter applied
+// when encoding, or empty.
+func (f *FFmpeg) args(opts Options, output string, copyAudio bool, filter string, in ffmpegInputs) []string {
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+	}
+
+	if opts.Progress != nil {
+		// Progress is written as key=value lines next to the errors
+		args = append(args, "-nostats", "-progress", "pipe:2")
+	}
+
+	args = append(args, "-i", "pipe:0")
+
+	// Extra inputs follow the audio, their index is their position
+	next := 1
+	coverInput, metadataInput := -1, -1
+	if in.cover != "" {
+		args = append(args, "-i", "file:"+in.cover)
+		coverInput, next = next, next+1
+	}
+	if in.metadata != "" {
+		args = append(args, "-i", "file:"+in.metadata)
+		metadataInput = next
+	}
+
+	if coverInput < 0 {
+		args = append(args, "-vn")
+	} else {
+		// The comment sets the picture type of the ID3 APIC frame
+		args = append(args,
+			"-map", "0:a", "-map", fmt.Sprintf("%d:v", coverInput),
+			"-c:v", "copy", "-disposition:v", "attached_pic",
+			"-metadata:s:v", "title=Album cover", "-metadata:s:v", "comment=Cover (front)",
+		)
+	}
+
+	if copyAudio {
+		args = append(args, "-c:a", "copy")
+	} else {
+		args = append(args,
+			"-c:a", opts.Format.Codec(),
+			"-ar", strconv.Itoa(opts.SampleRate),
+			"-ac", strconv.Itoa(opts.Channels),
+		)
+		args = append(args, bitrateArgs(opts)...)
+		if filter != "" {
+			args = append(args, "-af", filter)
+		}
+	}
+
+	args = append(args, clipArgs(opts)...)
+
+	// Drop the source container's tags, such as its encoder and language
+	args = append(args, "-map_metadata", strconv.Itoa(metadataInput))
+	if in.chapters {
+		args = append(args, "-map_chapters", strconv.Itoa(metadataInput))
+	}
+	args = append(args, metadataArgs(opts.Metadata)...)
+	if opts.Format == FormatMP3 {
+		args = append(args, "-id3v2_version", "4")
+	}
+
+	if opts.Format == FormatM4A {
+		if output == "-" {
+			// MP4 needs a seekable output unless it is fragmented
+			args = append(args, "-movflags", "frag_keyframe+empty_moov")
+		} else {
+			// Files are sent once complete, put the index first for players
+			// that start playing while downloading
+			args = append(args, "-movflags", "+faststart")
+		}
+	}
+
+	if output != "-" {
+		args = append(args, "-y")
+	}
+	return append(args, "-f", opts.Format.muxer(), output)
+}
+
+// clipArgs returns the output options cutting the audio to opts.Start and
+// opts.End. As output options the audio is decoded up to the start, which
+// is exact for every codec.
+func clipArgs(opts Options) []string {
+	var args []string
+	if opts.Start > 0 {
+		args = append(args, "-ss", ffmpegTime(opts.Start))
+	}
+	if opts.End > 0 {
+		args = append(args, "-t", ffmpegTime(opts.End-opts.Start))
+	}
+	return args
+}
+
+// ffmpegTime formats d in seconds for -ss and -t.
+func ffmpegTime(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// bitrateArgs returns the encoder flags for the bitrate mode.
+func bitrateArgs(opts Options) []string {
+	switch {
+	case opts.Format.Lossless():
+		// Lossless formats have no bitrate
+		return nil
+	case opts.Format == FormatMP3 && opts.Mode == ModeVBR:
+		quality, _ := parseQuality(opts.Quality)
+		return []string{"-q:a", strconv.Itoa(quality)}
+	case opts.Format.Codec() == "libopus":
+		return []string{"-b:a", opts.Bitrate, "-vbr", opusVBR[opts.Mode]}
+	default:
+		return []string{"-b:a", opts.Bitrate}
+	}
+}
+
+// opusVBR maps bitrate modes to the libopus -vbr setting.
+var opusVBR = map[BitrateMode]string{
+	ModeCBR:  "off",
+	ModeVBR:  "on",
+	ModeCVBR: "constrained",
+}
+
+// ffmpegStderr collects ffmpeg's error output and turns the key=value
+// lines written by -progress into encode progress updates.
+type ffmpegStderr struct {
+	progress ProgressFunc
+	errors   bytes.Buffer
+	partial  []byte
+}
+
+func (e *ffmpegStderr) Write(p []byte) (int, error) {
+	e.partial = append(e.partial, p...)
+	for {
+		i := bytes.IndexByte(e.partial, '\n')
+		if i < 0 {
+			break
+		}
+		e.line(string(e.partial[:i]))
+		e.partial = e.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (e *ffmpegStderr) line(line string) {
+	key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if ok && (progressKeys[key] || strings.HasPrefix(key, "stream_")) {
+		// out_time_ms is in microseconds as well, despite its name
+		if e.progress != nil && key == "out_time_us" {
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil {
+				e.progress(Progress{Phase: PhaseEncode, Encoded: time.Duration(us) * time.Microsecond})
+			}
+		}
+		return
+	}
+
+	e.errors.WriteString(line)
+	e.errors.WriteByte('\n')
+}
+
+// String returns the error output without progress lines.
+func (e *ffmpegStderr) String() string {
+	return e.errors.String() + string(e.partial)
+}
+
+// progressKeys are the keys ffmpeg writes with -progress.
+var progressKeys = map[string]bool{
+	"frame": true, "fps": true, "bitrate": true, "total_size": true,
+	"out_time_us": true, "out_time_ms": true, "out_time": true,
+	"dup_frames": true, "drop_frames": true, "speed": true, "progress": true,
+}
@@ -0,0 +1,82 @@
+package mp3
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFormatSeekable(t *testing.T) {
+	tests := []struct {
+		format Format
+		mode   BitrateMode
+		want   bool
+	}{
+		// Only VBR MP3 needs its Xing header rewritten
+		{FormatMP3, ModeVBR, true},
+		{FormatMP3, ModeCBR, false},
+		{FormatOpus, ModeVBR, false},
+		{FormatM4A, ModeCBR, false},
+		{FormatFLAC, "", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.format.seekable(tt.mode); got != tt.want {
+			t.Errorf("%s %s seekable = %v, want %v", tt.format, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestBitrateArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"mp3 cbr", Options{Format: FormatMP3, Mode: ModeCBR, Bitrate: "128k"}, []string{"-b:a", "128k"}},
+		{"mp3 vbr", Options{Format: FormatMP3, Mode: ModeVBR, Quality: "V2", Bitrate: "128k"}, []string{"-q:a", "2"}},
+		{"opus vbr", Options{Format: FormatOpus, Mode: ModeVBR, Bitrate: "96k"}, []string{"-b:a", "96k", "-vbr", "on"}},
+		{"webm cvbr", Options{Format: FormatWebM, Mode: ModeCVBR, Bitrate: "96k"}, []string{"-b:a", "96k", "-vbr", "constrained"}},
+		{"opus cbr", Options{Format: FormatOpus, Mode: ModeCBR, Bitrate: "96k"}, []string{"-b:a", "96k", "-vbr", "off"}},
+		{"m4a", Options{Format: FormatM4A, Mode: ModeCBR, Bitrate: "192k"}, []string{"-b:a", "192k"}},
+		{"flac", Options{Format: FormatFLAC, Bitrate: "192k"}, nil},
+	}
+
+	for _, tt := range tests {
+		if got := bitrateArgs(tt.opts); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: bitrateArgs = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFFmpegArgs(t *testing.T) {
+	f := &FFmpeg{}
+
+	opts := normalizeOptions(&Options{Bitrate: "128k", SampleRate: 44100, Channels: 2})
+	got := strings.Join(f.args(opts, "-", false, "", ffmpegInputs{}), " ")
+	want := "-hide_banner -loglevel error -i pipe:0 -vn -c:a libmp3lame -ar 44100 -ac 2 -b:a 128k -map_metadata -1 -id3v2_version 4 -f mp3 -"
+	if got != want {
+		t.Errorf("mp3 args =\n%s\nwant\n%s", got, want)
+	}
+
+	opts = normalizeOptions(&Options{Format: FormatM4A, Progress: func(Progress) {}})
+	args := f.args(opts, "-", true, "", ffmpegInputs{cover: "/tmp/cover.jpg", metadata: "/tmp/meta.txt", chapters: true})
+	for _, sub := range []string{
+		"-nostats -progress pipe:2",
+		"-i pipe:0 -i file:/tmp/cover.jpg -i file:/tmp/meta.txt",
+		"-map 0:a -map 1:v -c:v copy -disposition:v attached_pic",
+		"-c:a copy",
+		"-map_metadata 2 -map_chapters 2",
+		"-movflags frag_keyframe+empty_moov",
+	} {
+		if !strings.Contains(strings.Join(args, " "), sub) {
+			t.Errorf("m4a copy args %v do not contain %q", args, sub)
+		}
+	}
+
+	// Files are complete before they are sent
+	args = f.args(opts, "file:/tmp/out.m4a", false, "", ffmpegInputs{})
+	if !slices.Contains(args, "+faststart") || !slices.Contains(args, "-y") || args[len(args)-1] != "file:/tmp/out.m4a" {
+		t.Errorf("m4a file args = %v, want +faststart, -y and the file last", args)
+	}
+}
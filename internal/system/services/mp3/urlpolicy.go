@@ -0,0 +1,146 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// URLPolicy restricts the URLs the service accepts and the addresses it
+// connects to, so user supplied URLs cannot reach internal services.
+// Backends only ever get the canonical www.youtube.com URL of a video, so
+// the hosts of accepted URLs are never fetched themselves.
+type URLPolicy struct {
+	// Schemes lists the allowed URL schemes (default: https and http).
+	Schemes []string
+	// Hosts lists the hosts video URLs may use, hosts not known to
+	// ParseVideoURL are read like youtube.com. Entries starting with a dot
+	// match any subdomain (default: the YouTube hosts).
+	Hosts []string
+	// AllowPrivateNetworks lets the service's HTTP client connect to
+	// loopback and private addresses. Only enable it for trusted input.
+	AllowPrivateNetworks bool
+}
+
+// DefaultURLPolicy returns the policy used when WithURLPolicy is not given.
+func DefaultURLPolicy() URLPolicy {
+	return URLPolicy{
+		Schemes: []string{"https", "http"},
+		Hosts: []string{
+			"youtube.com", ".youtube.com",
+			"youtu.be",
+			"youtube-nocookie.com", ".youtube-nocookie.com",
+		},
+	}
+}
+
+// ParseVideoURL parses a video URL like the ParseVideoURL function,
+// accepting the schemes and hosts in p instead of the YouTube hosts and
+// refusing credentials in the URL. Errors wrap ErrInvalidURL, or
+// ErrURLNotAllowed when the URL is a video link the policy rejects.
+func (p URLPolicy) ParseVideoURL(raw string) (VideoRef, error) {
+	return parseVideoURL(raw, p.check)
+}
+
+func (p URLPolicy) check(u *url.URL) error {
+	host := strings.ToLower(u.Hostname())
+	if !p.allowsHost(host) {
+		return fmt.Errorf("%w: %q is not an allowed host", ErrInvalidURL, u.Hostname())
+	}
+
+	if !slices.Contains(p.Schemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("%w: scheme %q", ErrURLNotAllowed, u.Scheme)
+	}
+
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials in URL", ErrURLNotAllowed)
+	}
+	return nil
+}
+
+func (p URLPolicy) allowsHost(host string) bool {
+	for _, allowed := range p.Hosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598).
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublicAddr reports whether addr is a globally routable unicast address.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!addr.IsLoopback() &&
+		!addr.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// publicOnlyTransport returns an HTTP transport that refuses to connect
+// to internal addresses, even when a redirect or a thumbnail URL points
+// there. The proxy from HTTP_PROXY and HTTPS_PROXY is exempt, it is
+// configured by the operator and is often on an internal network.
+func publicOnlyTransport() *http.Transport {
+	base := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	public := *base
+	public.Control = func(network, address string, _ syscall.RawConn) error {
+		addrPort, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return err
+		}
+		if !isPublicAddr(addrPort.Addr()) {
+			return fmt.Errorf("%w: connection to internal address %s", ErrURLNotAllowed, addrPort.Addr())
+		}
+		return nil
+	}
+
+	// proxies holds the host:port of every proxy requests went through
+	var proxies sync.Map
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		proxy, err := http.ProxyFromEnvironment(req)
+		if proxy != nil {
+			proxies.Store(proxyAddr(proxy), true)
+		}
+		return proxy, err
+	}
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if _, ok := proxies.Load(address); ok {
+			return base.DialContext(ctx, network, address)
+		}
+		return public.DialContext(ctx, network, address)
+	}
+	return transport
+}
+
+// proxyAddr returns the host:port the transport dials for proxy.
+func proxyAddr(proxy *url.URL) string {
+	port := proxy.Port()
+	if port == "" {
+		switch proxy.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
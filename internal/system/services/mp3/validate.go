@@ -0,0 +1,269 @@
+package mp3
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FieldError is a problem with a single Options field.
+type FieldError struct {
+	// Field is the name of the Options field, such as "Bitrate".
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors lists every problem found by Options.Validate.
+// It matches ErrInvalidOptions with errors.Is.
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid options: " + strings.Join(msgs, "; ")
+}
+
+func (e ValidationErrors) Is(target error) bool {
+	return target == ErrInvalidOptions
+}
+
+// encoderLimits are the values an encoder accepts.
+type encoderLimits struct {
+	// sampleRates lists the accepted rates, when empty any rate between
+	// minSampleRate and maxSampleRate is accepted.
+	sampleRates   []int
+	minSampleRate int
+	maxSampleRate int
+	maxChannels   int
+	// minBitrate and maxBitrate are in bits per second, both are zero for
+	// lossless formats.
+	minBitrate int
+	maxBitrate int
+	// modes lists the supported bitrate modes, it is empty for lossless
+	// formats.
+	modes []BitrateMode
+}
+
+var formatLimits = map[Format]encoderLimits{
+	FormatMP3: {
+		sampleRates: []int{8000, 11025, 12000, 16000, 22050, 24000, 32000, 44100, 48000},
+		maxChannels: 2,
+		minBitrate:  8000,
+		maxBitrate:  320000,
+		modes:       []BitrateMode{ModeCBR, ModeVBR},
+	},
+	FormatM4A: {
+		sampleRates: []int{8000, 11025, 12000, 16000, 22050, 24000, 32000, 44100, 48000, 64000, 88200, 96000},
+		maxChannels: 8,
+		minBitrate:  8000,
+		maxBitrate:  512000,
+		modes:       []BitrateMode{ModeCBR},
+	},
+	FormatOpus: {
+		sampleRates: []int{8000, 12000, 16000, 24000, 48000},
+		maxChannels: 8,
+		minBitrate:  6000,
+		maxBitrate:  510000,
+		modes:       []BitrateMode{ModeCBR, ModeVBR, ModeCVBR},
+	},
+	FormatWebM: {
+		sampleRates: []int{8000, 12000, 16000, 24000, 48000},
+		maxChannels: 8,
+		minBitrate:  6000,
+		maxBitrate:  510000,
+		modes:       []BitrateMode{ModeCBR, ModeVBR, ModeCVBR},
+	},
+	FormatFLAC: {minSampleRate: 8000, maxSampleRate: 192000, maxChannels: 8},
+	FormatWAV:  {minSampleRate: 8000, maxSampleRate: 192000, maxChannels: 8},
+}
+
+func (l encoderLimits) acceptsSampleRate(rate int) bool {
+	if len(l.sampleRates) > 0 {
+		return slices.Contains(l.sampleRates, rate)
+	}
+	return rate >= l.minSampleRate && rate <= l.maxSampleRate
+}
+
+// mp3BitrateLimits narrows the MP3 bitrates by MPEG version, which is
+// picked by LAME from the sample rate.
+func mp3BitrateLimits(sampleRate int) (int, int) {
+	switch {
+	case sampleRate >= 32000: // MPEG-1
+		return 32000, 320000
+	case sampleRate >= 16000: // MPEG-2
+		return 8000, 160000
+	default: // MPEG-2.5
+		return 8000, 64000
+	}
+}
+
+// Validate checks the options against the limits of the output format's
+// encoder, after filling in defaults for zero values. Automatic settings
+// are always valid, they are resolved once the source is known. The returned error
+// is ValidationErrors with one entry per invalid field.
+func (o *Options) Validate() error {
+	opts := normalizeOptions(o)
+
+	limits, ok := formatLimits[opts.Format]
+	if !ok {
+		return ValidationErrors{{Field: "Format", Message: fmt.Sprintf("unsupported format %q", opts.Format)}}
+	}
+
+	var errs ValidationErrors
+
+	autoRate := opts.SampleRate == Auto
+	if !autoRate && len(limits.sampleRates) > 0 && !slices.Contains(limits.sampleRates, opts.SampleRate) {
+		errs = append(errs, &FieldError{
+			Field:   "SampleRate",
+			Message: fmt.Sprintf("%d Hz is not supported by %s, use one of %s", opts.SampleRate, opts.Format, joinInts(limits.sampleRates)),
+		})
+	}
+	if !autoRate && len(limits.sampleRates) == 0 && (opts.SampleRate < limits.minSampleRate || opts.SampleRate > limits.maxSampleRate) {
+		errs = append(errs, &FieldError{
+			Field:   "SampleRate",
+			Message: fmt.Sprintf("%d Hz is out of range for %s (%d-%d Hz)", opts.SampleRate, opts.Format, limits.minSampleRate, limits.maxSampleRate),
+		})
+	}
+
+	if opts.Channels != Auto && (opts.Channels < 1 || opts.Channels > limits.maxChannels) {
+		errs = append(errs, &FieldError{
+			Field:   "Channels",
+			Message: fmt.Sprintf("%d channels is not supported by %s (1-%d)", opts.Channels, opts.Format, limits.maxChannels),
+		})
+	}
+
+	if _, err := ParseBitrateMode(string(opts.Mode)); err != nil {
+		errs = append(errs, &FieldError{Field: "Mode", Message: err.Error()})
+	} else if !opts.Format.Lossless() && !slices.Contains(limits.modes, opts.Mode) {
+		errs = append(errs, &FieldError{
+			Field:   "Mode",
+			Message: fmt.Sprintf("%s is not supported by %s", opts.Mode, opts.Format),
+		})
+	}
+
+	if opts.Quality != "" {
+		if _, err := parseQuality(opts.Quality); err != nil {
+			errs = append(errs, &FieldError{Field: "Quality", Message: err.Error()})
+		}
+	}
+
+	if _, err := ParseSourcePreference(string(opts.Source.Prefer)); err != nil {
+		errs = append(errs, &FieldError{Field: "Source.Prefer", Message: err.Error()})
+	}
+	if opts.Source.TargetBitrate != "" {
+		if _, err := parseBitrate(opts.Source.TargetBitrate); err != nil {
+			errs = append(errs, &FieldError{Field: "Source.TargetBitrate", Message: err.Error()})
+		}
+	}
+
+	if _, err := ParseChapterSource(string(opts.Chapters)); err != nil {
+		errs = append(errs, &FieldError{Field: "Chapters", Message: err.Error()})
+	}
+
+	if opts.Metadata.Date != "" && !datePattern.MatchString(opts.Metadata.Date) {
+		errs = append(errs, &FieldError{Field: "Metadata.Date", Message: fmt.Sprintf("%q is not a date, use YYYY, YYYY-MM or YYYY-MM-DD", opts.Metadata.Date)})
+	}
+
+	switch {
+	case opts.Start < 0:
+		errs = append(errs, &FieldError{Field: "Start", Message: "must not be negative"})
+	case opts.End != 0 && opts.End <= opts.Start:
+		errs = append(errs, &FieldError{Field: "End", Message: fmt.Sprintf("must be after the start (%s)", opts.Start)})
+	}
+
+	if opts.Metadata.Track < 0 || opts.Metadata.TrackTotal < 0 || (opts.Metadata.TrackTotal > 0 && opts.Metadata.Track > opts.Metadata.TrackTotal) {
+		errs = append(errs, &FieldError{Field: "Metadata.Track", Message: fmt.Sprintf("%d of %d is not a track number", opts.Metadata.Track, opts.Metadata.TrackTotal)})
+	}
+
+	for i, c := range opts.Metadata.Chapters {
+		if c.Start < 0 || (c.End != 0 && c.End <= c.Start) || (i > 0 && c.Start < opts.Metadata.Chapters[i-1].Start) {
+			errs = append(errs, &FieldError{Field: "Metadata.Chapters", Message: fmt.Sprintf("chapter %d %q must not start before the previous one and must end after it starts", i+1, c.Title)})
+			break
+		}
+	}
+
+	switch {
+	case (opts.Cover || len(opts.Metadata.Cover) > 0) && !opts.Format.SupportsCover():
+		errs = append(errs, &FieldError{Field: "Cover", Message: fmt.Sprintf("%s files cannot embed cover art", opts.Format)})
+	case len(opts.Metadata.Cover) > 0:
+		if _, err := coverConfig(opts.Metadata.Cover); err != nil {
+			errs = append(errs, &FieldError{Field: "Metadata.Cover", Message: "must be a JPEG or PNG image"})
+		}
+	}
+
+	errs = append(errs, opts.Loudness.validate()...)
+
+	switch {
+	case opts.MaxSizeBytes < 0:
+		errs = append(errs, &FieldError{Field: "MaxSizeBytes", Message: "must not be negative"})
+	case opts.MaxSizeBytes > 0 && opts.Format.Lossless():
+		errs = append(errs, &FieldError{Field: "MaxSizeBytes", Message: fmt.Sprintf("%s is lossless, its size cannot be limited", opts.Format)})
+	case opts.MaxSizeBytes > 0 && opts.Format == FormatMP3 && opts.Mode == ModeVBR:
+		errs = append(errs, &FieldError{Field: "MaxSizeBytes", Message: "MP3 VBR has no target bitrate, use cbr to limit the size"})
+	}
+
+	// MP3 VBR picks the bitrate from Quality, so Bitrate is not used
+	// AutoBitrate is always capped to a valid value
+	if !opts.Format.Lossless() && !(opts.Format == FormatMP3 && opts.Mode == ModeVBR) && opts.Bitrate != AutoBitrate {
+		minRate, maxRate := limits.minBitrate, limits.maxBitrate
+		if opts.Format == FormatMP3 && !autoRate {
+			minRate, maxRate = mp3BitrateLimits(opts.SampleRate)
+		}
+
+		bitrate, err := parseBitrate(opts.Bitrate)
+		switch {
+		case err != nil:
+			errs = append(errs, &FieldError{Field: "Bitrate", Message: err.Error()})
+		case bitrate < minRate || bitrate > maxRate:
+			errs = append(errs, &FieldError{
+				Field:   "Bitrate",
+				Message: fmt.Sprintf("%s is out of range for %s at %d Hz (%dk-%dk)", opts.Bitrate, opts.Format, opts.SampleRate, minRate/1000, maxRate/1000),
+			})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// parseBitrate parses bitrates such as "128k" or "128000" into bits per second.
+func parseBitrate(s string) (int, error) {
+	digits := strings.ToLower(strings.TrimSpace(s))
+
+	multiplier := 1
+	if rest, ok := strings.CutSuffix(digits, "k"); ok {
+		digits, multiplier = rest, 1000
+	}
+
+	n, err := strconv.Atoi(digits)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid bitrate %q, use a value such as \"128k\"", s)
+	}
+	return n * multiplier, nil
+}
+
+// parseQuality parses a LAME VBR level such as "V2" or "2".
+func parseQuality(s string) (int, error) {
+	level, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "V"))
+	if err != nil || level < 0 || level > 9 {
+		return 0, fmt.Errorf("invalid VBR quality %q, use V0 (best) to V9 (smallest)", s)
+	}
+	return level, nil
+}
+
+func joinInts(values []int) string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = strconv.Itoa(v)
+	}
+	return strings.Join(strs, ", ")
+}
@@ -0,0 +1,71 @@
+package mp3
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		// fields are the invalid fields, none for valid options
+		fields []string
+	}{
+		{"defaults", Options{}, nil},
+		{"mp3 high quality", Options{Bitrate: "320k", SampleRate: 44100, Channels: 2}, nil},
+		{"automatic", Options{Bitrate: AutoBitrate, SampleRate: Auto, Channels: Auto}, nil},
+		{"mp3 vbr", Options{Mode: ModeVBR, Quality: "V2"}, nil},
+		{"opus", Options{Format: FormatOpus, Bitrate: "96k", SampleRate: 48000, Channels: 2}, nil},
+		{"flac ignores bitrate", Options{Format: FormatFLAC, Bitrate: "nonsense", SampleRate: 96000, Channels: 2}, nil},
+		{"unknown format", Options{Format: "ogv"}, []string{"Format"}},
+		{"mp3 sample rate", Options{SampleRate: 96000}, []string{"SampleRate"}},
+		{"opus sample rate", Options{Format: FormatOpus, SampleRate: 44100}, []string{"SampleRate"}},
+		{"mp3 channels", Options{Channels: 6}, []string{"Channels"}},
+		{"mp3 bitrate at 44.1 kHz", Options{Bitrate: "16k", SampleRate: 44100}, []string{"Bitrate"}},
+		{"mp3 bitrate at 22 kHz", Options{Bitrate: "320k", SampleRate: 22050}, []string{"Bitrate"}},
+		{"bad bitrate", Options{Bitrate: "fast"}, []string{"Bitrate"}},
+		{"m4a vbr", Options{Format: FormatM4A, Mode: ModeVBR}, []string{"Mode"}},
+		{"quality", Options{Mode: ModeVBR, Quality: "V11"}, []string{"Quality"}},
+		{"source", Options{Source: SourcePolicy{Prefer: "largest"}}, []string{"Source.Prefer"}},
+		{"chapters", Options{Chapters: "youtube"}, []string{"Chapters"}},
+		{"date", Options{Metadata: Metadata{Date: "March 2020"}}, []string{"Metadata.Date"}},
+		{"end before start", Options{Start: time.Minute, End: 30 * time.Second}, []string{"End"}},
+		{"negative start", Options{Start: -time.Second}, []string{"Start"}},
+		{"track", Options{Metadata: Metadata{Track: 3, TrackTotal: 2}}, []string{"Metadata.Track"}},
+		{"wav cover", Options{Format: FormatWAV, Cover: true}, []string{"Cover"}},
+		{"lossless size", Options{Format: FormatFLAC, MaxSizeBytes: 8e6}, []string{"MaxSizeBytes"}},
+		{"loudness", Options{Loudness: Loudness{Integrated: -80}}, []string{"Loudness.Integrated"}},
+		{"several", Options{SampleRate: 96000, Channels: 6}, []string{"SampleRate", "Channels"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidOptions) {
+				t.Fatalf("Validate() error = %v, want ErrInvalidOptions", err)
+			}
+			var fieldErrs ValidationErrors
+			if !errors.As(err, &fieldErrs) {
+				t.Fatalf("Validate() error is %T, want ValidationErrors", err)
+			}
+
+			var fields []string
+			for _, fe := range fieldErrs {
+				fields = append(fields, fe.Field)
+			}
+			if !slices.Equal(fields, tt.fields) {
+				t.Errorf("invalid fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package mp3
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VideoKind tells which kind of YouTube page a URL points to.
+type VideoKind string
+
+const (
+	// KindVideo is a regular video from a watch, embed or youtu.be link.
+	KindVideo VideoKind = "video"
+	// KindShort is a video from a /shorts/ link.
+	KindShort VideoKind = "short"
+	// KindLive is a live stream or its recording from a /live/ link.
+	KindLive VideoKind = "live"
+	// KindMusic is a video from music.youtube.com.
+	KindMusic VideoKind = "music"
+)
+
+// VideoRef is a parsed reference to a single YouTube video.
+type VideoRef struct {
+	// ID is the 11 character video ID.
+	ID string
+	// Start is the timestamp from a t= or start= parameter, 0 when absent.
+	Start time.Duration
+	// PlaylistID is the list= parameter, empty when absent. It is kept for
+	// reference only, conversions always use the single video.
+	PlaylistID string
+	Kind       VideoKind
+}
+
+// URL returns the canonical watch URL of the video, without playlist or
+// timestamp parameters.
+func (r VideoRef) URL() string {
+	return "https://www.youtube.com/watch?v=" + r.ID
+}
+
+// maxURLLength bounds the input accepted by ParseVideoURL.
+const maxURLLength = 2048
+
+var videoIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{11}$`)
+
+// youtubeHosts maps the hosts accepted by ParseVideoURL to the kind of
+// video they serve. Hosts a URLPolicy adds serve KindVideo.
+var youtubeHosts = map[string]VideoKind{
+	"youtube.com":              KindVideo,
+	"www.youtube.com":          KindVideo,
+	"m.youtube.com":            KindVideo,
+	"music.youtube.com":        KindMusic,
+	"youtu.be":                 KindVideo,
+	"youtube-nocookie.com":     KindVideo,
+	"www.youtube-nocookie.com": KindVideo,
+}
+
+// ParseVideoURL parses a YouTube video URL or a bare video ID.
+// It understands watch, youtu.be, /shorts/, /embed/, /live/ and /v/ links on
+// youtube.com, m.youtube.com and music.youtube.com, with or without a scheme,
+// and reads the start time from t= or start= parameters. Errors wrap
+// ErrInvalidURL and describe what is wrong with the input. Use
+// URLPolicy.ParseVideoURL to accept the hosts of a policy instead.
+func ParseVideoURL(raw string) (VideoRef, error) {
+	return parseVideoURL(raw, func(u *url.URL) error {
+		if _, ok := youtubeHosts[strings.ToLower(u.Hostname())]; !ok {
+			return fmt.Errorf("%w: %q is not a YouTube host", ErrInvalidURL, u.Hostname())
+		}
+		return nil
+	})
+}
+
+// parseVideoURL parses raw like ParseVideoURL. check accepts or rejects
+// the parsed URL, bare video IDs are not checked.
+func parseVideoURL(raw string, check func(u *url.URL) error) (VideoRef, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return VideoRef{}, fmt.Errorf("%w: empty URL", ErrInvalidURL)
+	}
+	if len(raw) > maxURLLength {
+		return VideoRef{}, fmt.Errorf("%w: URL is longer than %d characters", ErrInvalidURL, maxURLLength)
+	}
+
+	if videoIDPattern.MatchString(raw) {
+		return VideoRef{ID: raw, Kind: KindVideo}, nil
+	}
+
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return VideoRef{}, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return VideoRef{}, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidURL, u.Scheme)
+	}
+
+	if err := check(u); err != nil {
+		return VideoRef{}, err
+	}
+	kind := cmp.Or(youtubeHosts[strings.ToLower(u.Hostname())], KindVideo)
+
+	query := u.Query()
+	ref := VideoRef{Kind: kind, PlaylistID: query.Get("list")}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case strings.EqualFold(u.Hostname(), "youtu.be"):
+		ref.ID = segments[0]
+	case segments[0] == "watch":
+		ref.ID = query.Get("v")
+	case len(segments) >= 2 && segments[0] == "shorts":
+		ref.ID, ref.Kind = segments[1], KindShort
+	case len(segments) >= 2 && segments[0] == "live":
+		ref.ID, ref.Kind = segments[1], KindLive
+	case len(segments) >= 2 && (segments[0] == "embed" || segments[0] == "v" || segments[0] == "e"):
+		ref.ID = segments[1]
+	case segments[0] == "playlist":
+		return VideoRef{}, fmt.Errorf("%w: playlist links are not supported, link a single video", ErrInvalidURL)
+	default:
+		return VideoRef{}, fmt.Errorf("%w: %q is not a video link", ErrInvalidURL, u.Path)
+	}
+
+	if ref.ID == "" {
+		return VideoRef{}, fmt.Errorf("%w: missing video ID", ErrInvalidURL)
+	}
+	if !videoIDPattern.MatchString(ref.ID) {
+		return VideoRef{}, fmt.Errorf("%w: %q is not a valid video ID", ErrInvalidURL, ref.ID)
+	}
+
+	// The timestamp may also come in the fragment, as in #t=1m30s
+	fragment, _ := url.ParseQuery(u.Fragment)
+	for _, t := range []string{query.Get("t"), query.Get("start"), fragment.Get("t")} {
+		if t == "" {
+			continue
+		}
+
+		start, err := parseTimestamp(t)
+		if err != nil {
+			return VideoRef{}, fmt.Errorf("%w: invalid timestamp %q", ErrInvalidURL, t)
+		}
+		ref.Start = start
+		break
+	}
+
+	return ref, nil
+}
+
+// parseTimestamp parses YouTube timestamps: plain seconds ("90"),
+// seconds with a suffix ("90s") or units ("1h2m3s").
+func parseTimestamp(s string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(s); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,68 @@
+package mp3
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseVideoURL(t *testing.T) {
+	const id = "dQw4w9WgXcQ"
+
+	tests := []struct {
+		name string
+		raw  string
+		want VideoRef
+	}{
+		{"bare id", id, VideoRef{ID: id, Kind: KindVideo}},
+		{"watch", "https://www.youtube.com/watch?v=" + id, VideoRef{ID: id, Kind: KindVideo}},
+		{"without scheme", "youtube.com/watch?v=" + id, VideoRef{ID: id, Kind: KindVideo}},
+		{"mobile", "https://m.youtube.com/watch?v=" + id, VideoRef{ID: id, Kind: KindVideo}},
+		{"short link", "https://youtu.be/" + id, VideoRef{ID: id, Kind: KindVideo}},
+		{"shorts", "https://www.youtube.com/shorts/" + id, VideoRef{ID: id, Kind: KindShort}},
+		{"live", "https://www.youtube.com/live/" + id, VideoRef{ID: id, Kind: KindLive}},
+		{"embed", "https://www.youtube-nocookie.com/embed/" + id, VideoRef{ID: id, Kind: KindVideo}},
+		{"music", "https://music.youtube.com/watch?v=" + id, VideoRef{ID: id, Kind: KindMusic}},
+		{"playlist", "https://www.youtube.com/watch?v=" + id + "&list=PL123", VideoRef{ID: id, Kind: KindVideo, PlaylistID: "PL123"}},
+		{"seconds", "https://youtu.be/" + id + "?t=90", VideoRef{ID: id, Kind: KindVideo, Start: 90 * time.Second}},
+		{"units", "https://www.youtube.com/watch?v=" + id + "&t=1m30s", VideoRef{ID: id, Kind: KindVideo, Start: 90 * time.Second}},
+		{"start", "https://www.youtube.com/embed/" + id + "?start=45", VideoRef{ID: id, Kind: KindVideo, Start: 45 * time.Second}},
+		{"fragment", "https://www.youtube.com/watch?v=" + id + "#t=2m", VideoRef{ID: id, Kind: KindVideo, Start: 2 * time.Minute}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVideoURL(tt.raw)
+			if err != nil {
+				t.Fatalf("ParseVideoURL(%q) error: %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseVideoURL(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVideoURLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"empty", ""},
+		{"other host", "https://vimeo.com/watch?v=dQw4w9WgXcQ"},
+		{"scheme", "ftp://youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"playlist page", "https://www.youtube.com/playlist?list=PL123"},
+		{"channel", "https://www.youtube.com/@channel"},
+		{"missing id", "https://www.youtube.com/watch"},
+		{"short id", "https://youtu.be/abc"},
+		{"bad timestamp", "https://youtu.be/dQw4w9WgXcQ?t=soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseVideoURL(tt.raw); !errors.Is(err, ErrInvalidURL) {
+				t.Errorf("ParseVideoURL(%q) error = %v, want ErrInvalidURL", tt.raw, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package mp3
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// YTDLPExtractor extracts audio using the yt-dlp command line tool.
+type YTDLPExtractor struct {
+	// Path is the yt-dlp executable (default: "yt-dlp" looked up on PATH).
+	Path string
+	// FFmpegPath is passed to yt-dlp as --ffmpeg-location (default: "ffmpeg").
+	FFmpegPath string
+}
+
+// Name returns the extractor name.
+func (e *YTDLPExtractor) Name() string {
+	return "yt-dlp"
+}
+
+// Info retrieves video metadata by dumping the yt-dlp JSON description.
+func (e *YTDLPExtractor) Info(ctx context.Context, videoURL string) (*VideoInfo, error) {
+	data, err := e.dump(ctx, videoURL)
+	if err != nil {
+		return nil, err
+	}
+	return data.videoInfo(), nil
+}
+
+// dump runs yt-dlp --dump-single-json.
+func (e *YTDLPExtractor) dump(ctx context.Context, videoURL string) (*ytdlpInfo, error) {
+	path, err := e.lookPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, path,
+		"--no-warnings",
+		"--no-playlist",
+		"--dump-single-json",
+		// Everything after -- is positional, never an option
+		"--", videoURL,
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, ytdlpError(err, stderr.String())
+	}
+
+	var data ytdlpInfo
+	if err := json.Unmarshal(out, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp output: %w", err)
+	}
+
+	return &data, nil
+}
+
+// ytdlpFallback selects an audio stream when the video's formats are not
+// known. It never falls back to a stream with video.
+const ytdlpFallback = "bestaudio[ext=m4a]/bestaudio"
+
+// Open starts yt-dlp writing the audio stream chosen by opts.Source to its
+// standard output. The stream is picked from opts.Info, the video is only
+// looked up when it is nil.
+func (e *YTDLPExtractor) Open(ctx context.Context, videoURL string, opts Options) (*Stream, error) {
+	path, err := e.lookPath()
+	if err != nil {
+		return nil, err
+	}
+
+	ffmpegPath := e.FFmpegPath
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+
+	// Pick the format here rather than with a yt-dlp selector, so both
+	// extractors apply the same source policy
+	info := opts.Info
+	if info == nil {
+		data, err := e.dump(ctx, videoURL)
+		if err != nil {
+			return nil, err
+		}
+		info = data.videoInfo()
+	}
+
+	stream := &Stream{Duration: info.Duration, Info: info}
+	selector := ytdlpFallback
+	if len(info.AudioFormats) > 0 {
+		// No fallback, stream.Format must describe what is downloaded. A
+		// missing format fails and the next extractor is tried.
+		format := info.AudioFormats[rankAudioFormats(info.AudioFormats, info.Duration, opts)[0]]
+		selector, stream.Format, stream.Size = format.ID, format, format.Size
+	}
+
+	args := []string{
+		"--no-warnings",
+		"--quiet",
+		"--no-playlist",
+		"-f", selector,
+		"-o", "-",
+		"--ffmpeg-location", ffmpegPath,
+	}
+	if opts.Start > 0 || opts.End > 0 {
+		args = append(args, sectionArgs(opts, stream.Format)...)
+		// Only part of the stream is downloaded, its size is unknown
+		stream.Start, stream.Size = opts.Start, 0
+	}
+	// Everything after -- is positional, never an option
+	args = append(args, "--", videoURL)
+
+	cmd := exec.CommandContext(ctx, path, args...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	reader := &commandReader{Reader: stdout, pipe: stdout, cmd: cmd}
+	if opts.Progress != nil {
+		reader.Reader = &progressReader{Reader: stdout, fn: opts.Progress, total: stream.Size}
+	}
+	cmd.Stderr = &reader.stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start yt-dlp: %w", err)
+	}
+
+	stream.ReadCloser = reader
+	return stream, nil
+}
+
+// sectionArgs makes yt-dlp download only the part of the audio between
+// opts.Start and opts.End. ffmpeg fetches it with range requests, so the
+// rest of the stream is never downloaded.
+func sectionArgs(opts Options, format AudioFormat) []string {
+	end := "inf"
+	if opts.End > 0 {
+		end = ffmpegTime(opts.End)
+	}
+	args := []string{"--download-sections", "*" + ffmpegTime(opts.Start) + "-" + end}
+
+	// ffmpeg writes the section to the pipe, which MP4 needs fragmented
+	if format.Container == "m4a" || format.Container == "mp4" {
+		args = append(args, "--downloader-args", "ffmpeg_o:-movflags frag_keyframe+empty_moov")
+	}
+	return args
+}
+
+func (e *YTDLPExtractor) lookPath() (string, error) {
+	path := e.Path
+	if path == "" {
+		path = "yt-dlp"
+	}
+
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: yt-dlp not found", ErrBackendMissing)
+	}
+	return resolved, nil
+}
+
+// commandReader reads the standard output of a running command and
+// waits for the command to exit when closed.
+type commandReader struct {
+	io.Reader
+	pipe   io.Closer
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+	// eof is set once the command has closed its output
+	eof atomic.Bool
+
+	once sync.Once
+	err  error
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		r.eof.Store(true)
+	}
+	return n, err
+}
+
+// Close closes the output and waits for the command. A command closed
+// before the end of its output, such as when ffmpeg stops reading at
+// Options.End, fails writing to the closed pipe; the part that was read
+// is complete, so that is not an error.
+func (r *commandReader) Close() error {
+	r.once.Do(func() {
+		r.pipe.Close()
+		if err := r.cmd.Wait(); err != nil && r.eof.Load() {
+			r.err = ytdlpError(err, r.stderr.String())
+		}
+	})
+	return r.err
+}
+
+// ytdlpInfo is the part of the yt-dlp JSON description used by the service.
+type ytdlpInfo struct {
+	ID          string  `json:"id"`
+	Title       string  `json:"title"`
+	Uploader    string  `json:"uploader"`
+	Channel     string  `json:"channel"`
+	ChannelID   string  `json:"channel_id"`
+	Description string  `json:"description"`
+	Duration    float64 `json:"duration"`
+	UploadDate  string  `json:"upload_date"`
+	ViewCount   int64   `json:"view_count"`
+	Thumbnails  []struct {
+		URL    string `json:"url"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+	} `json:"thumbnails"`
+	Formats  []ytdlpFormat `json:"formats"`
+	Chapters []struct {
+		Title     string  `json:"title"`
+		StartTime float64 `json:"start_time"`
+		EndTime   float64 `json:"end_time"`
+	} `json:"chapters"`
+}
+
+type ytdlpFormat struct {
+	FormatID       string  `json:"format_id"`
+	Ext            string  `json:"ext"`
+	ACodec         string  `json:"acodec"`
+	VCodec         string  `json:"vcodec"`
+	ABR            float64 `json:"abr"`
+	Filesize       int64   `json:"filesize"`
+	FilesizeApprox int64   `json:"filesize_approx"`
+	ASR            int     `json:"asr"`
+	AudioChannels  int     `json:"audio_channels"`
+	Language       string  `json:"language"`
+}
+
+func (d *ytdlpInfo) videoInfo() *VideoInfo {
+	info := &VideoInfo{
+		Title:       d.Title,
+		Author:      cmp.Or(d.Uploader, d.Channel),
+		Duration:    time.Duration(d.Duration * float64(time.Second)),
+		VideoID:     d.ID,
+		ChannelID:   d.ChannelID,
+		Description: d.Description,
+		Views:       d.ViewCount,
+	}
+
+	// upload_date is formatted as YYYYMMDD
+	if date, err := time.Parse("20060102", d.UploadDate); err == nil {
+		info.PublishDate = date
+	}
+
+	for _, t := range d.Thumbnails {
+		info.Thumbnails = append(info.Thumbnails, Thumbnail{URL: t.URL, Width: t.Width, Height: t.Height})
+	}
+
+	for _, c := range d.Chapters {
+		info.Chapters = append(info.Chapters, Chapter{
+			Title: c.Title,
+			Start: time.Duration(c.StartTime * float64(time.Second)),
+			End:   time.Duration(c.EndTime * float64(time.Second)),
+		})
+	}
+
+	for _, f := range d.Formats {
+		// Audio-only formats have no video codec
+		if f.ACodec == "" || f.ACodec == "none" || (f.VCodec != "" && f.VCodec != "none") {
+			continue
+		}
+
+		info.AudioFormats = append(info.AudioFormats, AudioFormat{
+			ID:         f.FormatID,
+			Codec:      f.ACodec,
+			Container:  f.Ext,
+			Bitrate:    int(f.ABR * 1000),
+			Size:       cmp.Or(f.Filesize, f.FilesizeApprox),
+			SampleRate: f.ASR,
+			Channels:   f.AudioChannels,
+			Language:   f.Language,
+		})
+	}
+
+	return info
+}
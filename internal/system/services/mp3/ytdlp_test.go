@@ -0,0 +1,132 @@
+package mp3
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCommand writes an executable shell script running script and
+// returns its path.
+func fakeCommand(t *testing.T, script string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "fake")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestYTDLPOpenSelector(t *testing.T) {
+	// The fake yt-dlp writes its arguments as the audio
+	extractor := &YTDLPExtractor{Path: fakeCommand(t, `echo "$@"`)}
+
+	info := &VideoInfo{AudioFormats: []AudioFormat{
+		{ID: "140", Codec: "mp4a.40.2", Bitrate: 129000},
+		{ID: "251", Codec: "opus", Bitrate: 135000, Size: 4e6},
+	}}
+
+	tests := []struct {
+		name       string
+		info       *VideoInfo
+		wantFormat string
+		wantID     string
+	}{
+		{"picked format", info, "-f 251 ", "251"},
+		{"unknown formats", &VideoInfo{}, "-f " + ytdlpFallback + " ", ""},
+	}
+
+	for _, tt := range tests {
+		stream, err := extractor.Open(t.Context(), testVideoURL, Options{Info: tt.info})
+		if err != nil {
+			t.Fatalf("%s: Open error: %v", tt.name, err)
+		}
+		args, _ := io.ReadAll(stream)
+		if err := stream.Close(); err != nil {
+			t.Errorf("%s: Close error: %v", tt.name, err)
+		}
+
+		if !strings.Contains(string(args), tt.wantFormat) || !strings.HasSuffix(strings.TrimSpace(string(args)), "-- "+testVideoURL) {
+			t.Errorf("%s: args = %q, want %q and the URL after --", tt.name, args, tt.wantFormat)
+		}
+		if stream.Format.ID != tt.wantID {
+			t.Errorf("%s: Format.ID = %q, want %q", tt.name, stream.Format.ID, tt.wantID)
+		}
+	}
+}
+
+func TestCommandReaderClose(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		// all reads the output to the end before closing
+		all  bool
+		want error
+	}{
+		{"success", `echo audio`, true, nil},
+		{"download failure", `echo "ERROR: HTTP Error 403: Forbidden" >&2; exit 1`, true, ErrThrottled},
+		// ffmpeg stopped reading at the end of the clip
+		{"closed early", `while :; do echo audio; done`, false, nil},
+	}
+
+	for _, tt := range tests {
+		extractor := &YTDLPExtractor{Path: fakeCommand(t, tt.script)}
+		stream, err := extractor.Open(t.Context(), testVideoURL, Options{Info: &VideoInfo{}})
+		if err != nil {
+			t.Fatalf("%s: Open error: %v", tt.name, err)
+		}
+
+		if tt.all {
+			io.ReadAll(stream)
+		} else {
+			stream.Read(make([]byte, 16))
+		}
+
+		err = stream.Close()
+		if (tt.want == nil && err != nil) || !errors.Is(err, tt.want) {
+			t.Errorf("%s: Close error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestYTDLPVideoInfo(t *testing.T) {
+	const dump = `{
+		"id": "dQw4w9WgXcQ", "title": "Song", "channel": "Band", "duration": 212.5,
+		"upload_date": "20091025", "view_count": 1500000000,
+		"thumbnails": [{"url": "https://i.ytimg.com/vi/dQw4w9WgXcQ/hqdefault.jpg", "width": 480, "height": 360}],
+		"chapters": [{"title": "Intro", "start_time": 0, "end_time": 30.5}],
+		"formats": [
+			{"format_id": "251", "ext": "webm", "acodec": "opus", "vcodec": "none", "abr": 135.2, "filesize": 3500000, "asr": 48000, "audio_channels": 2, "language": "en"},
+			{"format_id": "18", "ext": "mp4", "acodec": "mp4a.40.2", "vcodec": "avc1.42001E", "abr": 96},
+			{"format_id": "sb0", "ext": "mhtml", "acodec": "none", "vcodec": "none"}
+		]
+	}`
+
+	var data ytdlpInfo
+	if err := json.Unmarshal([]byte(dump), &data); err != nil {
+		t.Fatal(err)
+	}
+	info := data.videoInfo()
+
+	if info.Title != "Song" || info.Author != "Band" || info.Duration != 212500*time.Millisecond || info.Views != 1500000000 {
+		t.Errorf("videoInfo = %+v", info)
+	}
+	if !info.PublishDate.Equal(time.Date(2009, 10, 25, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("PublishDate = %s, want 2009-10-25", info.PublishDate)
+	}
+	if len(info.Thumbnails) != 1 || len(info.Chapters) != 1 || info.Chapters[0].End != 30500*time.Millisecond {
+		t.Errorf("thumbnails %+v, chapters %+v", info.Thumbnails, info.Chapters)
+	}
+
+	// Only audio-only formats are candidates
+	want := AudioFormat{ID: "251", Codec: "opus", Container: "webm", Bitrate: 135200, Size: 3500000, SampleRate: 48000, Channels: 2, Language: "en"}
+	if len(info.AudioFormats) != 1 || info.AudioFormats[0] != want {
+		t.Errorf("AudioFormats = %+v, want %+v", info.AudioFormats, want)
+	}
+}